@@ -0,0 +1,171 @@
+package askcmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/openSUSE/kowalski/internal/app/ollamaconnector"
+	"github.com/openSUSE/kowalski/internal/app/server"
+	"github.com/openSUSE/kowalski/internal/pkg/actions"
+	"github.com/openSUSE/kowalski/internal/pkg/audit"
+	"github.com/openSUSE/kowalski/internal/pkg/database"
+	"github.com/openSUSE/kowalski/internal/pkg/file"
+	"github.com/spf13/cobra"
+)
+
+// output of ask with --output json
+type answer struct {
+	Question string                 `json:"question"`
+	Answer   string                 `json:"answer"`
+	Sources  []database.Source      `json:"sources"`
+	Commands []actions.Command      `json:"commands"`
+	Files    []actions.File         `json:"files"`
+	Stats    *ollamaconnector.Stats `json:"stats"`
+	// time for searching the documents and creating the prompt
+	RetrievalDuration time.Duration `json:"retrieval_duration"`
+}
+
+var askCmd = &cobra.Command{
+	Use:   "ask question",
+	Short: "Answer a single question",
+	Long: `Answer a question without the chat and print the answer to stdout.
+Input on stdin is added to the question, e.g.
+  journalctl -u sshd | kowalski ask "why does sshd fail"
+With --output json the answer, the sources, the proposed commands and
+files and the statistics of the modell are printed as json.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+		if output != "text" && output != "json" {
+			return fmt.Errorf("unknown output format: %s", output)
+		}
+		question := args[0]
+		for _, arg := range args[1:] {
+			question += " " + arg
+		}
+		input, err := readStdin()
+		if err != nil {
+			return err
+		}
+		locationStr, _ := cmd.Flags().GetString("location")
+		location := file.Local{
+			Chroot: locationStr,
+		}
+		var retriever database.Retriever
+		var llm ollamaconnector.Generator
+		var collections []string
+		model := ollamaconnector.Ollamasettings.LLM
+		if serverURL, _ := cmd.Flags().GetString("server"); serverURL != "" {
+			client := server.NewClient(serverURL)
+			if _, err := client.Health(); err != nil {
+				return err
+			}
+			retriever, llm, model = client, client, client.Model()
+			collections = client.ListCollections()
+		} else {
+			db, err := database.New()
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			retriever, llm = db, &ollamaconnector.Ollamasettings
+			collections = db.ListCollections()
+		}
+		start := time.Now()
+		prompt, err := database.BuildContext(retriever, question, collections, location, llm.GetContextSize(),
+			database.OptionWithInput(input))
+		if err != nil {
+			return err
+		}
+		retrieval := time.Since(start)
+		log.Debugf("Prompt: %s", prompt.Prompt)
+		ch := make(chan *ollamaconnector.TaskResponse)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- llm.SendTaskStream(cmd.Context(), prompt.Prompt, ch)
+		}()
+		parser := actions.Parser{}
+		var last *ollamaconnector.TaskResponse
+		for resp := range ch {
+			parser.Write(resp.Response)
+			if output == "text" {
+				fmt.Print(resp.Response)
+			}
+			last = resp
+		}
+		if output == "text" {
+			fmt.Println()
+		}
+		if err := <-errCh; err != nil {
+			return err
+		}
+		if last == nil || !last.Done {
+			return errors.New("answer of the modell is incomplete")
+		}
+		var sources []string
+		for _, src := range prompt.Sources {
+			sources = append(sources, src.Hash)
+		}
+		if err := audit.Log(audit.Entry{
+			Type:     audit.AnswerEntry,
+			Question: question,
+			Model:    model,
+			Sources:  sources,
+			Answer:   parser.String(),
+		}); err != nil {
+			log.Warnf("couldn't write audit log: %s", err)
+		}
+		if output == "json" {
+			plan := parser.Plan()
+			// empty lists instead of null are easier for scripts
+			ans := answer{
+				Question:          question,
+				Answer:            parser.String(),
+				Sources:           append([]database.Source{}, prompt.Sources...),
+				Commands:          append([]actions.Command{}, plan.Commands...),
+				Files:             append([]actions.File{}, plan.Files...),
+				Stats:             last.Stats(),
+				RetrievalDuration: retrieval,
+			}
+			out, err := json.MarshalIndent(ans, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+			return nil
+		}
+		// the sources go to stderr, so that stdout only has the answer
+		for i, src := range prompt.Sources {
+			fmt.Fprintf(os.Stderr, "[%d] %s (%s, dist: %.3f)\n", i+1, src.Title, src.Source, src.Dist)
+		}
+		return nil
+	},
+}
+
+// read the input if stdin isn't a terminal
+func readStdin() (string, error) {
+	stat, err := os.Stdin.Stat()
+	if err != nil || stat.Mode()&os.ModeCharDevice != 0 {
+		return "", nil
+	}
+	input, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", fmt.Errorf("couldn't read stdin: %w", err)
+	}
+	return string(input), nil
+}
+
+func init() {
+	askCmd.Flags().StringP("output", "o", "text", "output format: text or json")
+	askCmd.Flags().String("location", "", "location of the actual files")
+	askCmd.Flags().String("server", "", "URL of a kowalski server to use instead of the local database")
+}
+
+func GetCommand() *cobra.Command {
+	return askCmd
+}
@@ -0,0 +1,115 @@
+package auditcmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/openSUSE/kowalski/internal/pkg/audit"
+	"github.com/spf13/cobra"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "List the audit log",
+	Long: `Everything kowalski answered, every proposed or applied
+file change and every executed command is recorded in the audit log.
+List and filter its entries.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		filter := audit.Filter{}
+		entryType, _ := cmd.Flags().GetString("type")
+		filter.Type = audit.EntryType(entryType)
+		filter.Session, _ = cmd.Flags().GetString("session")
+		filter.Status, _ = cmd.Flags().GetString("status")
+		filter.Contains, _ = cmd.Flags().GetString("grep")
+		since, _ := cmd.Flags().GetString("since")
+		if filter.Since, err = parseTime(since); err != nil {
+			return err
+		}
+		until, _ := cmd.Flags().GetString("until")
+		if filter.Until, err = parseTime(until); err != nil {
+			return err
+		}
+		entries, err := audit.Read(filter)
+		if err != nil {
+			return err
+		}
+		if asJson, _ := cmd.Flags().GetBool("json"); asJson {
+			enc := json.NewEncoder(os.Stdout)
+			for _, entry := range entries {
+				if err = enc.Encode(entry); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+		fmt.Fprintln(w, "Time\tSession\tUser\tType\tStatus\tSummary")
+		for _, entry := range entries {
+			session := entry.Session
+			if len(session) > 8 {
+				session = session[:8]
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", entry.Time.Format(time.DateTime),
+				session, entry.User, entry.Type, entry.Status, summary(entry))
+		}
+		return w.Flush()
+	},
+}
+
+// one line description of the entry
+func summary(entry audit.Entry) (ret string) {
+	switch entry.Type {
+	case audit.AnswerEntry:
+		ret = entry.Question
+	case audit.CommandEntry:
+		ret = entry.Command
+		if entry.ExitCode != nil {
+			ret += fmt.Sprintf(" (exit %d)", *entry.ExitCode)
+		}
+	default:
+		ret = entry.Path
+	}
+	if entry.Error != "" {
+		ret += ": " + entry.Error
+	}
+	ret = strings.ReplaceAll(ret, "\n", " ")
+	if len(ret) > 80 {
+		ret = ret[:77] + "..."
+	}
+	return
+}
+
+// accept durations like 24h as well as dates
+func parseTime(str string) (time.Time, error) {
+	if str == "" {
+		return time.Time{}, nil
+	}
+	if dur, err := time.ParseDuration(str); err == nil {
+		return time.Now().Add(-dur), nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateTime, time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, str, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("couldn't parse time: %s", str)
+}
+
+func init() {
+	auditCmd.Flags().String("type", "", "only entries of type {answer,file,command,undo}")
+	auditCmd.Flags().String("session", "", "only entries of the session")
+	auditCmd.Flags().String("status", "", "only entries with status, e.g. applied or executed")
+	auditCmd.Flags().String("since", "", "only entries after the date or since the duration, e.g. 24h")
+	auditCmd.Flags().String("until", "", "only entries before the date or duration")
+	auditCmd.Flags().String("grep", "", "only entries containing the string")
+	auditCmd.Flags().Bool("json", false, "print the entries as json lines")
+}
+
+func GetCommand() *cobra.Command {
+	return auditCmd
+}
@@ -1,14 +1,21 @@
 package chatcmd
 
 import (
-	"strings"
+	"encoding/json"
+	"errors"
+	"fmt"
 
 	"github.com/charmbracelet/log"
+	"github.com/openSUSE/kowalski/internal/app/agent"
 	"github.com/openSUSE/kowalski/internal/app/chat"
 	"github.com/openSUSE/kowalski/internal/app/ollamaconnector"
+	"github.com/openSUSE/kowalski/internal/pkg/actions"
+	"github.com/openSUSE/kowalski/internal/pkg/audit"
 	"github.com/openSUSE/kowalski/internal/pkg/database"
 	"github.com/openSUSE/kowalski/internal/pkg/file"
+	"github.com/openSUSE/kowalski/internal/pkg/session"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // chatCmd represents the chat command
@@ -17,13 +24,52 @@ var chatCmd = &cobra.Command{
 	Short: "Ask kowalski what to change",
 	Long: `Start a chat with Kowalski, you helpfull penguin.
 He has access to knowledge bases and can access your files
-for better answers.`,
-	Run: func(cmd *cobra.Command, args []string) {
+for better answers.
+Proposed commands are only executed after confirmation and if they
+pass the policy, which can be extended in the configuration with
+regular expressions:
+  policy:
+    allow: ["^zypper ", "^systemctl "]
+    deny: ["^reboot"]
+With --server the documents are searched and the answers generated by
+a remote 'kowalski serve', the files are still read from this system.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
 		locationStr, _ := cmd.Flags().GetString("location")
 		location := file.Local{
 			Chroot: locationStr,
 		}
-		chat.Chat(&ollamaconnector.Ollamasettings, location)
+		rewrite, _ := cmd.Flags().GetBool("rewrite")
+		multi, _ := cmd.Flags().GetBool("multi-query")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		agentMode, _ := cmd.Flags().GetBool("agent")
+		maxSteps, _ := cmd.Flags().GetInt("max-steps")
+		serverURL, _ := cmd.Flags().GetString("server")
+		resume, _ := cmd.Flags().GetString("resume")
+		// as the id is optional, 'chat --resume ID' passes it as argument
+		if len(args) > 0 {
+			if resume != session.Latest {
+				return fmt.Errorf("unexpected argument: %s", args[0])
+			}
+			resume = args[0]
+		}
+		if serverURL != "" && agentMode {
+			return errors.New("agent mode needs a local database and ollama, it can't be used with a server")
+		}
+		policy, err := actions.NewPolicy(viper.GetStringSlice("policy.allow"), viper.GetStringSlice("policy.deny"))
+		if err != nil {
+			return err
+		}
+		return chat.Chat(&ollamaconnector.Ollamasettings, location, chat.ChatOpts{
+			Rewrite:    rewrite,
+			MultiQuery: multi,
+			Policy:     policy,
+			DryRun:     dryRun,
+			Agent:      agentMode,
+			MaxSteps:   maxSteps,
+			Server:     serverURL,
+			Resume:     resume,
+		})
 	},
 }
 
@@ -37,6 +83,7 @@ var reqCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		defer db.Close()
 		locationStr, _ := cmd.Flags().GetString("location")
 		location := file.Local{
 			Chroot: locationStr,
@@ -45,24 +92,72 @@ var reqCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		log.Infof("Prompt: %s", prompt)
+		log.Debugf("Prompt: %s", prompt.Prompt)
 		ch := make(chan *ollamaconnector.TaskResponse)
-		respStr := []string{}
-		go ollamaconnector.Ollamasettings.SendTaskStream(prompt, ch)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- ollamaconnector.Ollamasettings.SendTaskStream(cmd.Context(), prompt.Prompt, ch)
+		}()
+		parser := actions.Parser{}
 		for resp := range ch {
-			respStr = append(respStr, resp.Response)
+			parser.Write(resp.Response)
+		}
+		if err := <-errCh; err != nil {
+			return err
+		}
+		var sources []string
+		for _, src := range prompt.Sources {
+			sources = append(sources, src.Hash)
+		}
+		if err := audit.Log(audit.Entry{
+			Type:     audit.AnswerEntry,
+			Question: args[0],
+			Model:    ollamaconnector.Ollamasettings.LLM,
+			Sources:  sources,
+			Answer:   parser.String(),
+		}); err != nil {
+			log.Warnf("couldn't write audit log: %s", err)
+		}
+		if asJson, _ := cmd.Flags().GetBool("json"); asJson {
+			out, err := json.MarshalIndent(struct {
+				Answer  string            `json:"answer"`
+				Sources []database.Source `json:"sources"`
+				Plan    actions.Plan      `json:"plan"`
+			}{
+				Answer:  parser.String(),
+				Sources: prompt.Sources,
+				Plan:    parser.Plan(),
+			}, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+			return nil
+		}
+		log.Printf("Kowalski: %s", parser.String())
+		for i, src := range prompt.Sources {
+			log.Printf("[%d] %s (%s, dist: %.3f)", i+1, src.Title, src.Source, src.Dist)
 		}
-		log.Printf("Kowalski: %s", strings.Join(respStr, ``))
 		return nil
 	},
 	Args:    cobra.MinimumNArgs(1),
-	Aliases: []string{"query", "ask", "q", "req", "r"},
+	Aliases: []string{"query", "q", "req", "r"},
 	Hidden:  true,
 }
 
 func init() {
 	chatCmd.AddCommand(reqCmd)
-	chatCmd.PersistentFlags().String("location", "", "location of the actual files")
+	reqCmd.Flags().Bool("json", false, "print answer, sources and proposed actions as json")
+	reqCmd.Flags().String("location", "", "location of the actual files")
+	chatCmd.Flags().String("location", "", "location of the actual files")
+	chatCmd.Flags().Bool("rewrite", true, "rewrite follow up questions with the chat history before searching")
+	chatCmd.Flags().Bool("multi-query", false, "allow splitting the question in several search queries")
+	chatCmd.Flags().Bool("dry-run", false, "don't execute commands or write files")
+	chatCmd.Flags().Bool("agent", false, "let the modell inspect the system with read only tools")
+	chatCmd.Flags().Int("max-steps", agent.DefaultMaxSteps, "maximal number of tool rounds in agent mode")
+	chatCmd.Flags().String("server", "", "URL of a kowalski server to use instead of the local database")
+	chatCmd.Flags().String("resume", "", "resume the session with the given id, or the last one if no id is given")
+	chatCmd.Flags().Lookup("resume").NoOptDefVal = session.Latest
 }
 
 func GetCommand() *cobra.Command {
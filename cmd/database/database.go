@@ -195,7 +195,15 @@ var databaseGet = &cobra.Command{
 		}
 		switch oFormat {
 		case fullOut:
-			fmt.Println(info.Render(templates.RenderInfoWithMeta))
+			for _, sec := range info.Sections {
+				str, err := sec.Render(templates.Get(templates.RenderInfoWithMetaName), map[string]func(string) string{
+					"RenderFile": func(input string) string { return input },
+				})
+				if err != nil {
+					return err
+				}
+				fmt.Println(str)
+			}
 		case yamlOut:
 			str, _ := yaml.Marshal(info)
 			fmt.Println(string(str))
@@ -203,7 +211,7 @@ var databaseGet = &cobra.Command{
 			str, _ := json.MarshalIndent(info, "", "  ")
 			fmt.Println(string(str))
 		default:
-			fmt.Println(info.Render(templates.RenderTitleOnly))
+			fmt.Println(info.Render(templates.Get(templates.RenderTitleOnlyName)))
 		}
 		return nil
 	},
@@ -246,7 +254,7 @@ var databaseCheck = &cobra.Command{
 				str, _ := json.MarshalIndent(info, "", "  ")
 				fmt.Println(string(str))
 			default:
-				fmt.Printf("%s %s\n", info.Hash, info.Title)
+				fmt.Printf("%s %.3f %s\n", info.Hash, info.Dist, info.Title)
 			}
 		}
 		return nil
@@ -327,6 +335,8 @@ func init() {
 	databaseCmd.AddCommand(databaseList)
 	databaseCmd.AddCommand(databaseCheck)
 	databaseCheck.Flags().Int64P("number", "n", 5, "number of documents to retreive")
+	databaseCheck.Flags().Float64Var(&database.MMRLambda, "lambda", database.MMRLambda, "relevance vs. diversity of the retrieved documents (1: relevance only)")
+	databaseCheck.Flags().IntVar(&database.MaxPerDocument, "max-per-doc", database.MaxPerDocument, "maximal number of sections of one document (0: unlimited)")
 	databaseCmd.AddCommand(databaseGet)
 	databaseCmd.AddCommand(dropDocuments)
 	// databaseCmd.AddCommand(exportCollection)
@@ -0,0 +1,66 @@
+package doctorcmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/openSUSE/kowalski/internal/app/doctor"
+	"github.com/openSUSE/kowalski/internal/app/ollamaconnector"
+	"github.com/openSUSE/kowalski/internal/pkg/database"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the setup of kowalski",
+	Long: `Check if ollama is reachable, the modell and the embeddings of the
+collections are present and the knowledge database can be opened and
+searched. For every problem a fix is printed. Use --output json for
+attaching the report to a bug report.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+		if output != "text" && output != "json" {
+			return fmt.Errorf("unknown output format: %s", output)
+		}
+		report := doctor.Run(&ollamaconnector.Ollamasettings, database.DBLocation)
+		if output == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(report); err != nil {
+				return err
+			}
+		} else {
+			printReport(report)
+		}
+		if failed := report.Failed(); failed > 0 {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("%d checks failed", failed)
+		}
+		return nil
+	},
+}
+
+func printReport(report doctor.Report) {
+	fmt.Printf("kowalski %s, ollama %s, modell %s, database %s\n\n",
+		report.Version, report.OllamaURL, report.Model, report.Database)
+	for _, check := range report.Checks {
+		fmt.Printf("[%-4s] %s", check.Status, check.Name)
+		if check.Detail != "" {
+			fmt.Printf(": %s", check.Detail)
+		}
+		fmt.Println()
+		if check.Fix != "" {
+			fmt.Printf("       fix: %s\n", check.Fix)
+		}
+	}
+}
+
+func GetCommand() *cobra.Command {
+	return doctorCmd
+}
+
+func init() {
+	doctorCmd.Flags().StringP("output", "o", "text", "output format: text or json")
+}
@@ -36,13 +36,16 @@ So the input file foo.yaml will create an output like foo.yaml.abcd1234.`,
 		}
 		id := uuid.New()
 		evaluationList := evaluate.EvalutaionList{
-			Id:        id.String(),
-			Version:   version.Version,
-			LLM:       ollamaconnector.Ollamasettings.LLM,
-			Embedding: embedding,
+			Id:         id.String(),
+			Version:    version.Version,
+			LLM:        ollamaconnector.Ollamasettings.LLM,
+			Embedding:  embedding,
+			Lambda:     database.MMRLambda,
+			Generation: ollamaconnector.Ollamasettings.Generation(),
 		}
 		log.Infof("starting evaluation with id: %s", id.String())
-		log.Infof("LLM: %s embedding: %s", evaluationList.LLM, evaluationList.Embedding)
+		log.Infof("LLM: %s embedding: %s lambda: %.2f options: %v", evaluationList.LLM, evaluationList.Embedding, evaluationList.Lambda,
+			evaluationList.Generation.Options)
 		for _, fileName := range args {
 			file, err := os.ReadFile(fileName)
 			if err != nil {
@@ -76,8 +79,11 @@ So the input file foo.yaml will create an output like foo.yaml.abcd1234.`,
 			if err != nil {
 				return err
 			}
-			log.Debugf("Full prompt: %s", prompt)
-			resp, err := ollamaconnector.Ollamasettings.SendTask(prompt)
+			log.Debugf("Full prompt: %s", prompt.Prompt)
+			resp, err := ollamaconnector.Ollamasettings.SendTask(cmd.Context(), prompt.Prompt)
+			if err != nil {
+				return err
+			}
 			result := evaluate.EvlatuationResult{
 				Response:           resp.Response,
 				TotalDuration:      resp.TotalDuration,
@@ -86,10 +92,11 @@ So the input file foo.yaml will create an output like foo.yaml.abcd1234.`,
 				PromptEvalDuration: resp.PromptEvalDuration,
 				EvalCount:          resp.EvalCount,
 				EvalDuration:       resp.EvalDuration,
+				Generation:         evaluationList.Generation,
 				Evaluation:         *eval,
 			}
 			if context {
-				result.Context = prompt
+				result.Context = prompt.Prompt
 			}
 			log.Infof("TotalDuration %d, LoadDuration %d, PromptEvalCount  %d, PromptEvalDuration %d, EvalCount %d, EvalDuration %d", resp.TotalDuration, resp.LoadDuration, resp.PromptEvalCount, resp.PromptEvalDuration, resp.EvalCount, resp.EvalDuration)
 			log.Infof("response: %s", result.Response)
@@ -107,6 +114,8 @@ So the input file foo.yaml will create an output like foo.yaml.abcd1234.`,
 
 func init() {
 	runEvaluate.Flags().Bool("context", false, "include context in output")
+	runEvaluate.Flags().Float64Var(&database.MMRLambda, "lambda", database.MMRLambda, "relevance vs. diversity of the retrieved documents (1: relevance only)")
+	runEvaluate.Flags().IntVar(&database.MaxPerDocument, "max-per-doc", database.MaxPerDocument, "maximal number of sections of one document (0: unlimited)")
 }
 
 func GetCommand() *cobra.Command {
@@ -0,0 +1,42 @@
+package mcpcmd
+
+import (
+	"os"
+
+	"github.com/openSUSE/kowalski/internal/app/mcp"
+	"github.com/openSUSE/kowalski/internal/pkg/database"
+	"github.com/openSUSE/kowalski/internal/pkg/file"
+	"github.com/spf13/cobra"
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Serve the knowledge database over MCP",
+	Long: `Serve the knowledge database with the Model Context Protocol
+on stdin and stdout, so that editors and other agents can search the
+documentation. Configure it as stdio server with the command
+'kowalski mcp'.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := database.New()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		srv := mcp.Server{DB: db}
+		if systemFiles, _ := cmd.Flags().GetBool("system-files"); systemFiles {
+			locationStr, _ := cmd.Flags().GetString("location")
+			srv.Location = file.Local{Chroot: locationStr}
+		}
+		return srv.Serve(os.Stdin, os.Stdout)
+	},
+}
+
+func init() {
+	mcpCmd.Flags().Bool("system-files", false, "allow reading files of the system with the system_file tool")
+	mcpCmd.Flags().String("location", "", "location of the actual files")
+}
+
+func GetCommand() *cobra.Command {
+	return mcpCmd
+}
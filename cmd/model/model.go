@@ -0,0 +1,123 @@
+package modelcmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/openSUSE/kowalski/internal/app/ollamaconnector"
+	"github.com/spf13/cobra"
+)
+
+var modelCmd = &cobra.Command{
+	Use:   "model",
+	Short: "Manage the modells of ollama",
+	Long: `List, pull and remove the modells of the ollama instance given with --url.
+Missing modells are pulled with the first request, unless --no-auto-pull
+is set, so pulling them before avoids a long wait in the chat.`,
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the modells present in ollama",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		models, err := ollamaconnector.Ollamasettings.ListModels()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+		fmt.Fprintln(w, "Name\tSize\tParameters\tQuantization\tModified")
+		for _, model := range models {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", model.Name, humanSize(model.Size),
+				model.Details.ParameterSize, model.Details.QuantizationLevel, model.ModifiedAt.Format(time.DateTime))
+		}
+		return w.Flush()
+	},
+}
+
+var pullCmd = &cobra.Command{
+	Use:   "pull [NAME...]",
+	Short: "Pull modells, the one given with --modell if no name is given",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			args = []string{ollamaconnector.Ollamasettings.LLM}
+		}
+		for _, name := range args {
+			if err := pull(cmd, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+var rmCmd = &cobra.Command{
+	Use:   "rm NAME...",
+	Short: "Remove modells from ollama",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, name := range args {
+			if err := ollamaconnector.Ollamasettings.DeleteModel(name); err != nil {
+				return err
+			}
+			fmt.Printf("removed %s\n", name)
+		}
+		return nil
+	},
+}
+
+// pull with a progress line on a terminal, otherwise only the status changes are printed
+func pull(cmd *cobra.Command, name string) error {
+	progress := make(chan ollamaconnector.PullProgress)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- ollamaconnector.Ollamasettings.Pull(cmd.Context(), name, progress)
+	}()
+	stat, err := os.Stdout.Stat()
+	isTerm := err == nil && stat.Mode()&os.ModeCharDevice != 0
+	lastStatus := ""
+	for prog := range progress {
+		switch {
+		case isTerm && prog.Total > 0:
+			fmt.Printf("\r\033[K%s: %s %3.0f%% (%s/%s)", name, prog.Status,
+				100*prog.Fraction(), humanSize(prog.Completed), humanSize(prog.Total))
+		case prog.Status != lastStatus:
+			if isTerm {
+				fmt.Print("\r\033[K")
+			}
+			fmt.Printf("%s: %s\n", name, prog.Status)
+		}
+		lastStatus = prog.Status
+	}
+	if isTerm {
+		fmt.Print("\r\033[K")
+	}
+	if err := <-errCh; err != nil {
+		return err
+	}
+	fmt.Printf("%s: pulled\n", name)
+	return nil
+}
+
+func humanSize(size int64) string {
+	const unit = 1000
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "kMGTPE"[exp])
+}
+
+func init() {
+	modelCmd.AddCommand(listCmd, pullCmd, rmCmd)
+}
+
+func GetCommand() *cobra.Command {
+	return modelCmd
+}
@@ -4,11 +4,24 @@ import (
 	"fmt"
 	"os"
 
+	askcmd "github.com/openSUSE/kowalski/cmd/ask"
+	auditcmd "github.com/openSUSE/kowalski/cmd/audit"
 	chatcmd "github.com/openSUSE/kowalski/cmd/chat"
 	databasecmd "github.com/openSUSE/kowalski/cmd/database"
+	doctorcmd "github.com/openSUSE/kowalski/cmd/doctor"
 	evaluatecmd "github.com/openSUSE/kowalski/cmd/evaluate"
+	mcpcmd "github.com/openSUSE/kowalski/cmd/mcp"
+	modelcmd "github.com/openSUSE/kowalski/cmd/model"
+	servecmd "github.com/openSUSE/kowalski/cmd/serve"
+	sessionscmd "github.com/openSUSE/kowalski/cmd/sessions"
+	templatescmd "github.com/openSUSE/kowalski/cmd/templates"
+	undocmd "github.com/openSUSE/kowalski/cmd/undo"
 	"github.com/openSUSE/kowalski/internal/app/ollamaconnector"
+	"github.com/openSUSE/kowalski/internal/app/templatecheck"
+	"github.com/openSUSE/kowalski/internal/pkg/audit"
 	"github.com/openSUSE/kowalski/internal/pkg/database"
+	"github.com/openSUSE/kowalski/internal/pkg/file"
+	"github.com/openSUSE/kowalski/internal/pkg/templates"
 	"github.com/openSUSE/kowalski/internal/pkg/version"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -37,7 +50,7 @@ distribution documentation.`,
 				os.Exit(0)
 			}
 		},
-		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			initConfig()
 			if debug, _ := cmd.Flags().GetBool("debug"); debug {
 				log.SetLevel(log.DebugLevel)
@@ -49,17 +62,42 @@ distribution documentation.`,
 					cmd.Flags().Set(conf_name, fmt.Sprintf("%v", val))
 				}
 			})
+			if err := ollamaconnector.Ollamasettings.SetProfiles(viper.Get("models")); err != nil {
+				return err
+			}
+			templates.Model = ollamaconnector.Ollamasettings.LLM
+			if err := templates.Load(templatecheck.Check); err != nil {
+				log.Warnf("using the built-in templates instead of:\n%s", err)
+			}
+			opts, _ := cmd.Flags().GetStringArray("opt")
+			return ollamaconnector.Ollamasettings.SetOverrides(opts)
 		},
 	}
 	rootCmd.PersistentFlags().StringVar(&ollamaconnector.Ollamasettings.LLM, "modell", "gemma3:1b", "LLM modell to be used for answers")
 	rootCmd.PersistentFlags().StringVar(&ollamaconnector.Ollamasettings.OllamaURL, "url", "http://localhost:11434", "base URL for ollama requests")
+	rootCmd.PersistentFlags().StringArray("opt", nil, "generation option for ollama as key=value, e.g. num_ctx=8192, overrides the models section of the config")
+	rootCmd.PersistentFlags().BoolVar(&ollamaconnector.Ollamasettings.NoAutoPull, "no-auto-pull", false, "don't pull missing modells, fail instead")
 	rootCmd.PersistentFlags().StringVar(&database.DBLocation, "database", "/usr/lib/kowalski", "path to knowledge database")
+	rootCmd.PersistentFlags().Float64Var(&database.ContextFraction, "context-fraction", database.ContextFraction, "fraction of the modell context which can be filled with documents")
+	rootCmd.PersistentFlags().IntVar(&database.AnswerReserve, "answer-reserve", database.AnswerReserve, "tokens kept free for the answer")
+	rootCmd.PersistentFlags().Int64Var(&database.ContextCandidates, "context-candidates", database.ContextCandidates, "number of sections retrieved before packing the context")
+	rootCmd.PersistentFlags().StringVar(&file.BackupDir, "backup-dir", file.BackupDir, "directory for the backups of changed files")
+	rootCmd.PersistentFlags().StringVar(&audit.LogPath, "audit-log", audit.LogPath, "path of the audit log, empty to disable")
 	rootCmd.PersistentFlags().BoolP("debug", "d", false, "turn on debugging messages")
 	// viper.BindPFlags(rootCmd.PersistentFlags())
 	// when this action is called directly.
 	rootCmd.AddCommand(chatcmd.GetCommand())
+	rootCmd.AddCommand(askcmd.GetCommand())
 	rootCmd.AddCommand(databasecmd.GetCommand())
 	rootCmd.AddCommand(evaluatecmd.GetCommand())
+	rootCmd.AddCommand(undocmd.GetCommand())
+	rootCmd.AddCommand(auditcmd.GetCommand())
+	rootCmd.AddCommand(mcpcmd.GetCommand())
+	rootCmd.AddCommand(servecmd.GetCommand())
+	rootCmd.AddCommand(sessionscmd.GetCommand())
+	rootCmd.AddCommand(doctorcmd.GetCommand())
+	rootCmd.AddCommand(modelcmd.GetCommand())
+	rootCmd.AddCommand(templatescmd.GetCommand())
 	rootCmd.AddCommand(versCmd)
 	rootCmd.Flags().BoolP("version", "v", false, "print version (git tag)")
 	return rootCmd
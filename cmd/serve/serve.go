@@ -0,0 +1,52 @@
+package servecmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/openSUSE/kowalski/internal/app/ollamaconnector"
+	"github.com/openSUSE/kowalski/internal/app/server"
+	"github.com/openSUSE/kowalski/internal/pkg/database"
+	"github.com/openSUSE/kowalski/internal/pkg/file"
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the knowledge database over HTTP",
+	Long: `Serve the knowledge database and the LLM with a HTTP API, so that
+several clients can share one database. The JSON schema of the requests
+and responses is available under /api/v1/schema.
+OpenAI compatible clients can use the base URL http://<listen>/v1, the
+answers of /v1/chat/completions are based on the documentation.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := database.New()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		srv := server.Server{
+			DB:  db,
+			LLM: &ollamaconnector.Ollamasettings,
+		}
+		if locationStr, _ := cmd.Flags().GetString("location"); locationStr != "" {
+			srv.Location = file.Local{Chroot: locationStr}
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		listen, _ := cmd.Flags().GetString("listen")
+		return srv.Run(ctx, listen)
+	},
+}
+
+func init() {
+	serveCmd.Flags().String("listen", "localhost:8080", "address to listen on")
+	serveCmd.Flags().String("location", "", "location of the files presented to the LLM, none if empty")
+}
+
+func GetCommand() *cobra.Command {
+	return serveCmd
+}
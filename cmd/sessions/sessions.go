@@ -0,0 +1,119 @@
+package sessionscmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/openSUSE/kowalski/internal/pkg/session"
+	"github.com/spf13/cobra"
+)
+
+// length of the ids in the list, a unique prefix is enough to select one
+const shortId = 8
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Manage the saved chat sessions",
+	Long: `Chat sessions are saved with their messages, sources and the applied
+actions. A session can be resumed with 'chat --resume ID' or exported as
+transcript. Instead of the full id a unique prefix can be used.`,
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the sessions, the most recent first",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sessions, err := session.List()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+		fmt.Fprintln(w, "Id\tUpdated\tMessages\tActions\tQuestion")
+		for _, sess := range sessions {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", sess.Id[:min(shortId, len(sess.Id))],
+				sess.Updated.Format(time.DateTime), len(sess.Messages), len(sess.Actions), sess.Title())
+		}
+		return w.Flush()
+	},
+}
+
+var showCmd = &cobra.Command{
+	Use:   "show ID",
+	Short: "Print the messages of a session",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sess, err := session.Load(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Session %s, started %s\n", sess.Id, sess.Created.Format(time.DateTime))
+		for _, msg := range sess.Messages {
+			fmt.Printf("\n%s: %s\n", msg.Sender, strings.TrimSpace(msg.Text))
+			for i, src := range msg.Sources {
+				fmt.Printf("  [%d] %s (%s)\n", i+1, src.Title, src.Source)
+			}
+		}
+		if len(sess.Actions) > 0 {
+			fmt.Println("\nActions:")
+			for _, action := range sess.Actions {
+				fmt.Println("  " + session.FormatAction(action))
+			}
+		}
+		return nil
+	},
+}
+
+var deleteCmd = &cobra.Command{
+	Use:   "delete ID...",
+	Short: "Delete sessions",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, id := range args {
+			if err := session.Delete(id); err != nil {
+				return err
+			}
+			fmt.Printf("deleted %s\n", id)
+		}
+		return nil
+	},
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export ID",
+	Short: "Write a session as markdown or json to stdout",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sess, err := session.Load(args[0])
+		if err != nil {
+			return err
+		}
+		format, _ := cmd.Flags().GetString("format")
+		switch format {
+		case "markdown", "md":
+			fmt.Print(sess.Markdown())
+		case "json":
+			out, err := json.MarshalIndent(sess, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+		default:
+			return fmt.Errorf("unknown format: %s", format)
+		}
+		return nil
+	},
+}
+
+func init() {
+	sessionsCmd.AddCommand(listCmd, showCmd, deleteCmd, exportCmd)
+	exportCmd.Flags().String("format", "markdown", "format of the export: markdown or json")
+}
+
+func GetCommand() *cobra.Command {
+	return sessionsCmd
+}
@@ -0,0 +1,124 @@
+package templatescmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/openSUSE/kowalski/internal/app/templatecheck"
+	"github.com/openSUSE/kowalski/internal/pkg/templates"
+	"github.com/spf13/cobra"
+)
+
+var templatesCmd = &cobra.Command{
+	Use:   "templates",
+	Short: "Show, check and render the prompt templates",
+	Long: `The built-in templates can be overridden by files named NAME.tmpl in
+/etc/kowalski/templates or ~/.config/kowalski/templates. Templates for a
+single modell are read from a subdirectory named like the modell. Broken
+overrides are reported at startup and the built-in template is used.`,
+}
+
+var showCmd = &cobra.Command{
+	Use:   "show [NAME]",
+	Short: "List the templates or print the one with NAME",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+			fmt.Fprintln(w, "Name\tOrigin")
+			for _, name := range templates.Names() {
+				fmt.Fprintf(w, "%s\t%s\n", name, templates.Origin(name))
+			}
+			return w.Flush()
+		}
+		text, ok := templates.Builtin(args[0])
+		if !ok {
+			return unknown(args[0])
+		}
+		if builtin, _ := cmd.Flags().GetBool("builtin"); !builtin {
+			text = templates.Get(args[0])
+		}
+		fmt.Print(text)
+		return nil
+	},
+}
+
+var checkCmd = &cobra.Command{
+	Use:   "check [FILE...]",
+	Short: "Check the templates in use or the given files",
+	Long: `Render the templates with sample data. Files are checked against the
+template named like the file without .tmpl, so an override can be
+checked before it's installed.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		if len(args) == 0 {
+			err := templates.Load(templatecheck.Check)
+			for _, name := range templates.Names() {
+				fmt.Printf("ok %s: %s\n", name, templates.Origin(name))
+			}
+			return err
+		}
+		var errs []error
+		for _, path := range args {
+			name := strings.TrimSuffix(filepath.Base(path), ".tmpl")
+			if _, ok := templates.Builtin(name); !ok {
+				errs = append(errs, fmt.Errorf("%s: %w", path, unknown(name)))
+				continue
+			}
+			content, err := os.ReadFile(path)
+			if err == nil {
+				err = templatecheck.Check(name, string(content))
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", path, err))
+				continue
+			}
+			fmt.Printf("ok %s: %s\n", name, path)
+		}
+		return errors.Join(errs...)
+	},
+}
+
+var renderCmd = &cobra.Command{
+	Use:   "render NAME [QUESTION...]",
+	Short: "Render a template with sample data for the question",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		if _, ok := templates.Builtin(name); !ok {
+			return unknown(name)
+		}
+		text := templates.Get(name)
+		if path, _ := cmd.Flags().GetString("file"); path != "" {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			text = string(content)
+		}
+		out, err := templatecheck.Render(name, text, strings.Join(args[1:], " "))
+		if err != nil {
+			return err
+		}
+		fmt.Print(out)
+		return nil
+	},
+}
+
+func unknown(name string) error {
+	return fmt.Errorf("unknown template %s, known are: %s", name, strings.Join(templates.Names(), ", "))
+}
+
+func GetCommand() *cobra.Command {
+	return templatesCmd
+}
+
+func init() {
+	showCmd.Flags().Bool("builtin", false, "print the built-in template instead of the override")
+	renderCmd.Flags().StringP("file", "f", "", "render this file instead of the template in use")
+	templatesCmd.AddCommand(showCmd, checkCmd, renderCmd)
+}
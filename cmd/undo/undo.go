@@ -0,0 +1,69 @@
+package undocmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/charmbracelet/log"
+	"github.com/openSUSE/kowalski/internal/pkg/audit"
+	"github.com/openSUSE/kowalski/internal/pkg/file"
+	"github.com/spf13/cobra"
+)
+
+var undoCmd = &cobra.Command{
+	Use:   "undo [BACKUPID]",
+	Short: "Restore files changed by kowalski",
+	Long: `Every file change accepted in the chat is backed up before
+it is written. Restore the latest change or the one with the given
+backup id, which can be found with 'undo --list'.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if list, _ := cmd.Flags().GetBool("list"); list {
+			backups, err := file.ListBackups()
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+			fmt.Fprintln(w, "Id\tPath\tNew")
+			for _, backup := range backups {
+				for _, entry := range backup.Entries {
+					fmt.Fprintf(w, "%s\t%s\t%v\n", backup.Id, entry.Path, !entry.Existed)
+				}
+			}
+			return w.Flush()
+		}
+		id := ""
+		if len(args) > 0 {
+			id = args[0]
+		}
+		backup, err := file.Undo(id)
+		if err != nil {
+			return err
+		}
+		for _, entry := range backup.Entries {
+			if err := audit.Log(audit.Entry{
+				Type:   audit.UndoEntry,
+				Status: audit.Restored,
+				Path:   entry.Path,
+				Backup: backup.Id,
+			}); err != nil {
+				log.Warnf("couldn't write audit log: %s", err)
+			}
+			if entry.Existed {
+				fmt.Printf("restored %s\n", entry.Path)
+			} else {
+				fmt.Printf("removed %s\n", entry.Path)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	undoCmd.Flags().BoolP("list", "l", false, "list the available backups")
+}
+
+func GetCommand() *cobra.Command {
+	return undoCmd
+}
@@ -0,0 +1,133 @@
+/*
+Agent mode lets the modell gather the information it needs with read only
+tools, instead of getting all the information in a single prompt.
+*/
+package agent
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"text/template"
+
+	"github.com/charmbracelet/log"
+	"github.com/openSUSE/kowalski/internal/app/ollamaconnector"
+	"github.com/openSUSE/kowalski/internal/pkg/database"
+	"github.com/openSUSE/kowalski/internal/pkg/templates"
+)
+
+// default for the maximal number of tool rounds before an answer is forced
+const DefaultMaxSteps = 5
+
+type Tool struct {
+	Definition ollamaconnector.Tool
+	Call       func(args map[string]any) (string, error)
+}
+
+// a tool call of the modell and its result
+type Step struct {
+	Tool      string
+	Arguments map[string]any
+	Result    string
+	Err       error
+}
+
+// sends the conversation to the modell, like ollamaconnector.Settings
+type Chatter interface {
+	SendChat(ctx context.Context, messages []ollamaconnector.Message, tools []ollamaconnector.Tool) (*ollamaconnector.ChatResponse, error)
+}
+
+type Agent struct {
+	LLM      Chatter
+	Tools    []Tool
+	MaxSteps int
+	// called after every tool call
+	OnStep func(Step)
+}
+
+// system prompt for the agent
+func SystemPrompt() (string, error) {
+	tmpl, err := template.New("agent").Parse(templates.Get(templates.AgentPromptName))
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, database.GetSystemInfo())
+	return buf.String(), err
+}
+
+// messages for the question with the system prompt and the history
+func Messages(question string, history []database.Turn) ([]ollamaconnector.Message, error) {
+	system, err := SystemPrompt()
+	if err != nil {
+		return nil, err
+	}
+	messages := []ollamaconnector.Message{{Role: "system", Content: system}}
+	for _, turn := range history {
+		messages = append(messages,
+			ollamaconnector.Message{Role: "user", Content: turn.Question},
+			ollamaconnector.Message{Role: "assistant", Content: turn.Answer})
+	}
+	return append(messages, ollamaconnector.Message{Role: "user", Content: question}), nil
+}
+
+/*
+Run the conversation until the modell answers without tool calls. After
+MaxSteps rounds of tool calls the modell has to answer without tools.
+*/
+func (agent *Agent) Run(ctx context.Context, messages []ollamaconnector.Message) (answer string, err error) {
+	maxSteps := agent.MaxSteps
+	if maxSteps <= 0 {
+		maxSteps = DefaultMaxSteps
+	}
+	var definitions []ollamaconnector.Tool
+	tools := make(map[string]Tool)
+	for _, tool := range agent.Tools {
+		definitions = append(definitions, tool.Definition)
+		tools[tool.Definition.Function.Name] = tool
+	}
+	for range maxSteps {
+		resp, err := agent.LLM.SendChat(ctx, messages, definitions)
+		if err != nil {
+			return "", err
+		}
+		messages = append(messages, resp.Message)
+		if len(resp.Message.Tool_Calls) == 0 {
+			return resp.Message.Content, nil
+		}
+		for _, call := range resp.Message.Tool_Calls {
+			step := Step{
+				Tool:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+			}
+			if tool, ok := tools[step.Tool]; ok {
+				step.Result, step.Err = tool.Call(step.Arguments)
+			} else {
+				step.Err = fmt.Errorf("unknown tool: %s", step.Tool)
+			}
+			log.Debugf("tool %s(%v): %s %v", step.Tool, step.Arguments, step.Result, step.Err)
+			if agent.OnStep != nil {
+				agent.OnStep(step)
+			}
+			content := step.Result
+			if step.Err != nil {
+				content = "Error: " + step.Err.Error()
+			}
+			messages = append(messages, ollamaconnector.Message{
+				Role:     "tool",
+				Content:  content,
+				ToolName: step.Tool,
+			})
+		}
+	}
+	log.Debugf("reached step limit of %d", maxSteps)
+	messages = append(messages, ollamaconnector.Message{
+		Role:    "user",
+		Content: "You reached the limit of tool calls, answer now with the gathered information.",
+	})
+	resp, err := agent.LLM.SendChat(ctx, messages, nil)
+	if err != nil {
+		return "", err
+	}
+	return resp.Message.Content, nil
+}
@@ -0,0 +1,95 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openSUSE/kowalski/internal/app/ollamaconnector"
+)
+
+// calls the tool in every request with tools and records the requests
+type fakeChat struct {
+	tool     string
+	answerIn int
+	requests [][]ollamaconnector.Message
+	tools    [][]ollamaconnector.Tool
+}
+
+func (chat *fakeChat) SendChat(ctx context.Context, messages []ollamaconnector.Message, tools []ollamaconnector.Tool) (*ollamaconnector.ChatResponse, error) {
+	chat.requests = append(chat.requests, append([]ollamaconnector.Message(nil), messages...))
+	chat.tools = append(chat.tools, tools)
+	resp := &ollamaconnector.ChatResponse{Done: true}
+	resp.Message.Role = "assistant"
+	if tools == nil || len(chat.requests) == chat.answerIn {
+		resp.Message.Content = "the answer"
+		return resp, nil
+	}
+	var call ollamaconnector.ToolCall
+	call.Function.Name = chat.tool
+	call.Function.Arguments = map[string]any{"name": "vim"}
+	resp.Message.Tool_Calls = []ollamaconnector.ToolCall{call}
+	return resp, nil
+}
+
+func echoTool() Tool {
+	var def ollamaconnector.Tool
+	def.Function.Name = "echo"
+	return Tool{
+		Definition: def,
+		Call: func(args map[string]any) (string, error) {
+			return "echo " + args["name"].(string), nil
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		tool     string
+		maxSteps int
+		answerIn int
+		requests int
+		result   string
+	}{
+		{"answer after a tool call", "echo", 0, 2, 2, "echo vim"},
+		{"default step limit", "echo", 0, 0, DefaultMaxSteps + 1, "echo vim"},
+		{"step limit", "echo", 2, 0, 3, "echo vim"},
+		{"unknown tool", "rm", 1, 0, 2, "Error: unknown tool: rm"},
+	}
+	for _, test := range tests {
+		chat := &fakeChat{tool: test.tool, answerIn: test.answerIn}
+		var steps int
+		kwAgent := Agent{
+			LLM:      chat,
+			Tools:    []Tool{echoTool()},
+			MaxSteps: test.maxSteps,
+			OnStep:   func(Step) { steps++ },
+		}
+		answer, err := kwAgent.Run(context.Background(), []ollamaconnector.Message{{Role: "user", Content: "question"}})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if answer != "the answer" {
+			t.Errorf("%s: answer %q", test.name, answer)
+		}
+		if len(chat.requests) != test.requests {
+			t.Fatalf("%s: %d requests, want %d", test.name, len(chat.requests), test.requests)
+		}
+		if steps != test.requests-1 {
+			t.Errorf("%s: %d steps, want %d", test.name, steps, test.requests-1)
+		}
+		for i := 1; i < len(chat.requests); i++ {
+			msgs := chat.requests[i]
+			last := msgs[len(msgs)-1]
+			if i == len(chat.requests)-1 && test.answerIn == 0 {
+				if chat.tools[i] != nil {
+					t.Errorf("%s: last request has tools", test.name)
+				}
+				last = msgs[len(msgs)-2]
+			}
+			if last.Role != "tool" || last.ToolName != test.tool || last.Content != test.result {
+				t.Errorf("%s: request %d ends with %+v", test.name, i, last)
+			}
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/openSUSE/kowalski/internal/app/ollamaconnector"
+	"github.com/openSUSE/kowalski/internal/pkg/database"
+	"github.com/openSUSE/kowalski/internal/pkg/file"
+)
+
+// maximal size of a tool result handed to the modell
+const maxResult = 4096
+
+const toolTimeout = 10 * time.Second
+
+// names of packages and units, they can't start with a - so that no
+// options can be injected
+var nameRegEx = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9@_.+:-]*$`)
+
+/*
+Toolbox contains the read only tools for the agent. The documents found
+with the knowledge base search are collected in Sources.
+*/
+type Toolbox struct {
+	Location    file.Location
+	Chroot      string
+	DB          *database.Knowledge
+	Collections []string
+	Sources     []database.Source
+}
+
+func definition(name, description string, params map[string]string) ollamaconnector.Tool {
+	properties := make(map[string]any)
+	required := []string{}
+	for param, desc := range params {
+		properties[param] = map[string]any{
+			"type":        "string",
+			"description": desc,
+		}
+		required = append(required, param)
+	}
+	return ollamaconnector.Tool{
+		Type: "function",
+		Function: ollamaconnector.ToolFunction{
+			Name:        name,
+			Description: description,
+			Parameters: map[string]any{
+				"type":       "object",
+				"properties": properties,
+				"required":   required,
+			},
+		},
+	}
+}
+
+func stringArg(args map[string]any, name string) (string, error) {
+	val, ok := args[name].(string)
+	if !ok || val == "" {
+		return "", fmt.Errorf("missing argument: %s", name)
+	}
+	return val, nil
+}
+
+func truncate(str string) string {
+	if len(str) > maxResult {
+		return str[:maxResult] + "\n[truncated]"
+	}
+	return str
+}
+
+func (box *Toolbox) Tools() (tools []Tool) {
+	tools = append(tools,
+		Tool{
+			Definition: definition("read_file", "Read the content of a file on the system",
+				map[string]string{"path": "absolute path of the file"}),
+			Call: func(args map[string]any) (string, error) {
+				path, err := stringArg(args, "path")
+				if err != nil {
+					return "", err
+				}
+				content, err := box.Location.Read(path)
+				return truncate(content), err
+			},
+		},
+		Tool{
+			Definition: definition("list_directory", "List the entries of a directory on the system",
+				map[string]string{"path": "absolute path of the directory"}),
+			Call: func(args map[string]any) (string, error) {
+				path, err := stringArg(args, "path")
+				if err != nil {
+					return "", err
+				}
+				entries, err := box.Location.List(path)
+				return truncate(strings.Join(entries, "\n")), err
+			},
+		},
+		Tool{
+			Definition: definition("rpm_query", "Check if a package is installed and get its version",
+				map[string]string{"package": "name of the package"}),
+			Call: func(args map[string]any) (string, error) {
+				pkg, err := stringArg(args, "package")
+				if err != nil {
+					return "", err
+				}
+				cmd := []string{"rpm", "-q", pkg}
+				if box.Chroot != "" {
+					cmd = []string{"rpm", "--root", box.Chroot, "-q", pkg}
+				}
+				return run(pkg, cmd...)
+			},
+		},
+		Tool{
+			Definition: definition("systemctl_status", "Get the status of a systemd unit",
+				map[string]string{"unit": "name of the unit, e.g. sshd.service"}),
+			Call: func(args map[string]any) (string, error) {
+				unit, err := stringArg(args, "unit")
+				if err != nil {
+					return "", err
+				}
+				// the runtime state of a chroot isn't known, only if it's enabled
+				if box.Chroot != "" {
+					return run(unit, "systemctl", "--root", box.Chroot, "is-enabled", unit)
+				}
+				return run(unit, "systemctl", "status", "--no-pager", "--lines=10", unit)
+			},
+		},
+	)
+	if box.DB != nil {
+		tools = append(tools, Tool{
+			Definition: definition("search_knowledge_base", "Search the system documentation",
+				map[string]string{"query": "what to search for"}),
+			Call: box.search,
+		})
+	}
+	return
+}
+
+func (box *Toolbox) search(args map[string]any) (string, error) {
+	query, err := stringArg(args, "query")
+	if err != nil {
+		return "", err
+	}
+	collections := box.Collections
+	if len(collections) == 0 {
+		collections = box.DB.ListCollections()
+	}
+	infos, err := box.DB.GetInfos(query, collections, 3)
+	if err != nil {
+		return "", err
+	}
+	var out []string
+	for _, info := range infos {
+		str, err := info.Section.Render(map[string]func(string) string{
+			"FileInfo": func(input string) string { return box.Location.Get(input) },
+		})
+		if err != nil {
+			return "", err
+		}
+		out = append(out, str)
+		box.Sources = append(box.Sources, database.Source{
+			Title:  info.Title,
+			Source: info.Source,
+			Hash:   info.Hash,
+			Index:  info.Index,
+			Dist:   info.Dist,
+		})
+	}
+	if len(out) == 0 {
+		return "nothing found", nil
+	}
+	return truncate(strings.Join(out, "\n")), nil
+}
+
+// run the command, a failing exit code is part of the result
+func run(name string, args ...string) (string, error) {
+	if !nameRegEx.MatchString(name) {
+		return "", fmt.Errorf("invalid name: %s", name)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), toolTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return truncate(string(out)), nil
+	}
+	return truncate(string(out)), err
+}
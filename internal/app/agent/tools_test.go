@@ -0,0 +1,26 @@
+package agent
+
+import "testing"
+
+func TestNameRegEx(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"openssh-server", true},
+		{"sshd.service", true},
+		{"getty@tty1.service", true},
+		{"libstdc++6", true},
+		{"python3.11-pip", true},
+		{"-h", false},
+		{"--user", false},
+		{"", false},
+		{"vim; reboot", false},
+		{"a b", false},
+	}
+	for _, test := range tests {
+		if valid := nameRegEx.MatchString(test.name); valid != test.valid {
+			t.Errorf("%q: valid %v, want %v", test.name, valid, test.valid)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/openSUSE/kowalski/internal/app/agent"
+	"github.com/openSUSE/kowalski/internal/pkg/database"
+	"github.com/openSUSE/kowalski/internal/pkg/file"
+)
+
+// send for every tool call in agent mode
+type toolStepMsg agent.Step
+
+func (step toolStepMsg) String() string {
+	var args []string
+	for key, val := range step.Arguments {
+		args = append(args, fmt.Sprintf("%s=%v", key, val))
+	}
+	sort.Strings(args)
+	call := fmt.Sprintf("%s(%s)", step.Tool, strings.Join(args, ", "))
+	if step.Err != nil {
+		return fmt.Sprintf("%s failed: %s", call, step.Err)
+	}
+	lines := strings.Count(strings.TrimRight(step.Result, "\n"), "\n") + 1
+	if step.Result == "" {
+		lines = 0
+	}
+	return fmt.Sprintf("%s → %d lines", call, lines)
+}
+
+// the agent with the tools for the current location and collections
+func (m *uimodel) newAgent() (*agent.Agent, *agent.Toolbox) {
+	box := &agent.Toolbox{
+		Location:    m.location,
+		DB:          m.db,
+		Collections: m.collections,
+	}
+	if loc, ok := m.location.(file.Local); ok {
+		box.Chroot = loc.Chroot
+	}
+	return &agent.Agent{
+		LLM:      m.ollama,
+		Tools:    box.Tools(),
+		MaxSteps: m.opts.MaxSteps,
+		OnStep: func(step agent.Step) {
+			uiProc.Send(toolStepMsg(step))
+		},
+	}, box
+}
+
+/*
+Answer the question with the agent, every tool call is shown in the chat.
+Runs in the background, so the agent is created before by newAgent.
+*/
+func talkAgent(ctx context.Context, kwAgent *agent.Agent, box *agent.Toolbox, msg string, history []database.Turn) {
+	messages, err := agent.Messages(msg, history)
+	if err != nil {
+		uiProc.Send(errMsg(err))
+		return
+	}
+	answer, err := kwAgent.Run(ctx, messages)
+	if ctx.Err() != nil {
+		uiProc.Send(contextMsg{prompt: database.PromptContext{Sources: box.Sources}})
+		uiProc.Send(llmDone{question: msg, interrupted: true})
+		return
+	}
+	if err != nil {
+		uiProc.Send(errMsg(err))
+		return
+	}
+	uiProc.Send(contextMsg{prompt: database.PromptContext{Sources: box.Sources}})
+	uiProc.Send(LLMAns(answer))
+	uiProc.Send(llmDone{question: msg})
+}
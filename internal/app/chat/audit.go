@@ -0,0 +1,77 @@
+package chat
+
+import (
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/openSUSE/kowalski/internal/pkg/actions"
+	"github.com/openSUSE/kowalski/internal/pkg/audit"
+)
+
+func (m *uimodel) audit(entry audit.Entry) {
+	entry.Session = m.session.Id
+	entry.Time = time.Now()
+	if err := audit.Log(entry); err != nil {
+		log.Warnf("couldn't write audit log: %s", err)
+	}
+	// the session keeps what happened to the proposed actions
+	if entry.Type != audit.AnswerEntry && entry.Status != audit.Proposed {
+		m.session.Actions = append(m.session.Actions, entry)
+		m.saveSession()
+	}
+}
+
+// record the answer and the actions proposed in it
+func (m *uimodel) auditAnswer(question string, answer chatMessage) {
+	var sources []string
+	for _, src := range answer.sources {
+		sources = append(sources, src.Hash)
+	}
+	entry := audit.Entry{
+		Type:     audit.AnswerEntry,
+		Question: question,
+		Model:    m.model,
+		Sources:  sources,
+		Answer:   answer.text,
+	}
+	if answer.interrupted {
+		entry.Status = audit.Interrupted
+	}
+	m.audit(entry)
+	for _, f := range answer.plan.Files {
+		before, _ := m.location.Read(f.Path)
+		m.audit(audit.Entry{
+			Type:   audit.FileEntry,
+			Status: audit.Proposed,
+			Path:   f.Path,
+			Before: audit.Checksum(before),
+			After:  audit.Checksum(f.Content),
+		})
+	}
+	for _, cmd := range answer.plan.Commands {
+		m.audit(audit.Entry{
+			Type:    audit.CommandEntry,
+			Status:  audit.Proposed,
+			Command: cmd.Command,
+		})
+	}
+}
+
+// record the result of a command of the review
+func (m *uimodel) auditCommand(res actions.Result, err error) {
+	entry := audit.Entry{
+		Type:    audit.CommandEntry,
+		Command: res.Command,
+		Status:  audit.Executed,
+	}
+	switch {
+	case err != nil:
+		entry.Status = audit.Failed
+		entry.Error = err.Error()
+	case res.DryRun:
+		entry.Status = audit.DryRun
+	default:
+		entry.ExitCode = &res.ExitCode
+	}
+	m.audit(entry)
+}
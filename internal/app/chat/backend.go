@@ -0,0 +1,78 @@
+package chat
+
+import (
+	"errors"
+
+	"github.com/openSUSE/kowalski/internal/app/ollamaconnector"
+	"github.com/openSUSE/kowalski/internal/app/server"
+	"github.com/openSUSE/kowalski/internal/pkg/database"
+	"github.com/openSUSE/kowalski/internal/pkg/information"
+)
+
+/*
+Where the documents are searched and the answers are generated, either
+the local database with ollama or a remote kowalski server. The prompt is
+always created in the chat, so that it contains the local files.
+*/
+type backend interface {
+	database.Retriever
+	ollamaconnector.Generator
+	GetSection(id string, index int) (information.Section, error)
+	ListCollections() []string
+	// check if ollama or the server can be reached
+	Ping() error
+}
+
+// the knowledge is nil if the database couldn't be opened
+type localBackend struct {
+	*database.Knowledge
+	*ollamaconnector.Settings
+}
+
+var errNoDatabase = errors.New("no knowledge database")
+
+func (b localBackend) ListCollections() []string {
+	if b.Knowledge == nil {
+		return nil
+	}
+	return b.Knowledge.ListCollections()
+}
+
+func (b localBackend) GetInfos(msg string, collections []string, nrDocs int64) ([]information.RetSection, error) {
+	if b.Knowledge == nil {
+		return nil, errNoDatabase
+	}
+	return b.Knowledge.GetInfos(msg, collections, nrDocs)
+}
+
+func (b localBackend) GetSection(id string, index int) (information.Section, error) {
+	if b.Knowledge == nil {
+		return information.Section{}, errNoDatabase
+	}
+	return b.Knowledge.GetSection(id, index)
+}
+
+func (b localBackend) Ping() error {
+	_, err := b.FindModel(b.LLM)
+	return err
+}
+
+// used when there is nothing to search, the modell answers on its own
+type noRetrieval struct{}
+
+func (noRetrieval) GetInfos(string, []string, int64) ([]information.RetSection, error) {
+	return nil, nil
+}
+
+// open the backend and return the name of the model answering
+func openBackend(llm *ollamaconnector.Settings, opts ChatOpts) (backend, *database.Knowledge, string, error) {
+	if opts.Server != "" {
+		client := server.NewClient(opts.Server)
+		if _, err := client.Health(); err != nil {
+			return client, nil, "", err
+		}
+		return client, nil, client.Model(), nil
+	}
+	db, err := database.New()
+	return localBackend{Knowledge: db, Settings: llm}, db, llm.LLM, err
+}
@@ -1,27 +1,57 @@
 package chat
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/user"
 	"strings"
-	"sync"
 
+	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/log"
 	"github.com/openSUSE/kowalski/internal/app/ollamaconnector"
+	"github.com/openSUSE/kowalski/internal/pkg/actions"
 	"github.com/openSUSE/kowalski/internal/pkg/database"
 	"github.com/openSUSE/kowalski/internal/pkg/file"
+	"github.com/openSUSE/kowalski/internal/pkg/session"
+	"github.com/openSUSE/kowalski/internal/pkg/xdg"
 )
 
 const gap = "\n\n"
 
 var uiProc *tea.Program
 
-func Chat(llm *ollamaconnector.Settings, location file.Location) error {
+// options of a chat session
+type ChatOpts struct {
+	// rewrite follow up questions with the history before retrieval
+	Rewrite bool
+	// allow the rewrite to split the question in several queries
+	MultiQuery bool
+	// policy for executing the proposed commands
+	Policy *actions.Policy
+	// don't execute commands or write files
+	DryRun bool
+	// let the LLM inspect the system with tools
+	Agent bool
+	// maximal number of tool rounds in agent mode
+	MaxSteps int
+	// URL of a kowalski server which is used instead of the local database
+	Server string
+	// id of the session to resume, session.Latest for the last one
+	Resume string
+}
+
+// warnings of the chat, as they can't be written to the terminal
+var logFile = xdg.StateHome("chat.log")
+
+// number of previous turns sent along with a question
+const historyTurns = 4
+
+func Chat(llm *ollamaconnector.Settings, location file.Location, opts ChatOpts) error {
 	if log.GetLevel() <= log.DebugLevel {
 		f, err := tea.LogToFile("debug.log", "debug")
 		if err != nil {
@@ -30,13 +60,29 @@ func Chat(llm *ollamaconnector.Settings, location file.Location) error {
 		}
 		log.SetOutput(f)
 		defer f.Close()
+	} else if f, err := openLog(); err == nil {
+		// warnings would mess up the screen, the problems are shown in the banner
+		log.SetOutput(f)
+		defer f.Close()
+		defer log.SetOutput(os.Stderr)
 	}
-	uimodel := initialModel(llm, location)
+	var sess *session.Session
+	if opts.Resume != "" {
+		var err error
+		if sess, err = session.Load(opts.Resume); err != nil {
+			return err
+		}
+	}
+	uimodel := initialModel(llm, location, opts, sess)
 	uiProc = tea.NewProgram(&uimodel)
 	if _, err := uiProc.Run(); err != nil {
 
 		return err
 	}
+	uimodel.saveSession()
+	if len(uimodel.session.Messages) > 0 {
+		fmt.Printf("session saved, resume it with: kowalski chat --resume %s\n", uimodel.session.Id)
+	}
 	return nil
 }
 
@@ -44,31 +90,76 @@ type (
 	errMsg error
 )
 
+// a message in the chat, answers carry the documents they are based on
+type chatMessage struct {
+	sender  string
+	text    string
+	sources []database.Source
+	plan    actions.Plan
+	// rendered answer, empty if the text or the width changed
+	rendered string
+	// the generation was stopped by the user
+	interrupted bool
+}
+
 type uimodel struct {
 	viewport    viewport.Model
-	inputs      []string
-	answer      string
+	messages    []chatMessage
 	textarea    textarea.Model
 	senderStyle lipgloss.Style
+	sourceStyle lipgloss.Style
+	planStyle   lipgloss.Style
+	errStyle    lipgloss.Style
 	ollama      *ollamaconnector.Settings
 	location    file.Location
 	uid         string
-	mutex       sync.Mutex
 	isRunning   bool
+	showSources bool
+	opts        ChatOpts
+	history     []database.Turn
+	session     *session.Session
+	review      *actionReview
+	runner      actions.Runner
 	err         error
 	db          *database.Knowledge
+	backend     backend
+	model       string
+	answers     *answerRenderer
+	// stops the running generation
+	cancel context.CancelFunc
+	// searched collections, all if empty
+	collections []string
+	// prompt of the last question
+	lastPrompt string
+	// typed inputs, persisted across sessions
+	inputs *inputHistory
+	// height of the terminal
+	height int
+	status statusBar
+	// the database couldn't be opened
+	dbErr error
+	// ollama or the server can't be reached
+	backendErr error
+	// a health check is pending
+	checking bool
+	// banner the layout was computed for
+	shownBanner string
+	// modell which is pulled
+	pull *pullState
 }
 
-func initialModel(llm *ollamaconnector.Settings, location file.Location) uimodel {
+func initialModel(llm *ollamaconnector.Settings, location file.Location, opts ChatOpts, sess *session.Session) uimodel {
 	ta := textarea.New()
-	ta.Placeholder = "Type CTR-C or ESC to quit..."
+	ta.Placeholder = "Type /help for commands, CTR-C or ESC to quit, CTR-X to stop an answer, CTR-O to toggle sources..."
 	ta.Focus()
 
 	ta.Prompt = "┃ "
-	ta.CharLimit = 280
+	// allow to paste error messages and config files
+	ta.CharLimit = 0
+	ta.MaxHeight = 0
 
 	ta.SetWidth(30)
-	ta.SetHeight(3)
+	ta.SetHeight(minInputHeight)
 
 	// Remove cursor line styling
 	ta.FocusedStyle.CursorLine = lipgloss.NewStyle()
@@ -78,28 +169,61 @@ func initialModel(llm *ollamaconnector.Settings, location file.Location) uimodel
 	vp := viewport.New(30, 5)
 	vp.SetContent(`Welcome to a system configuration prompt.`)
 
-	ta.KeyMap.InsertNewline.SetEnabled(false)
+	// enter sends the input
+	ta.KeyMap.InsertNewline.SetKeys("alt+enter", "ctrl+j")
 	uid, _ := user.Current()
-	db, err := database.New()
+	chroot := ""
+	if loc, ok := location.(file.Local); ok {
+		chroot = loc.Chroot
+	}
+	// the problems are shown in the banner
+	var dbErr, backendErr error
+	backend, db, model, err := openBackend(llm, opts)
 	if err != nil {
-		log.Warnf("couldn't access database: %s", err)
+		log.Warnf("couldn't open backend: %s", err)
+		if opts.Server != "" {
+			backendErr = err
+		} else {
+			dbErr = err
+		}
+	}
+	if sess == nil {
+		sess = session.New(model)
 	}
 
 	return uimodel{
 		textarea:    ta,
-		inputs:      []string{},
+		messages:    restoreMessages(sess),
+		history:     sess.Turns,
 		viewport:    vp,
 		senderStyle: lipgloss.NewStyle().Foreground(lipgloss.Color("5")),
+		sourceStyle: lipgloss.NewStyle().Foreground(lipgloss.Color("8")),
+		planStyle:   lipgloss.NewStyle().Foreground(lipgloss.Color("3")),
+		errStyle:    lipgloss.NewStyle().Foreground(lipgloss.Color("1")),
 		err:         nil,
 		ollama:      llm,
 		location:    location,
 		uid:         uid.Username,
 		db:          db,
+		backend:     backend,
+		model:       model,
+		answers:     newAnswerRenderer(),
+		inputs:      loadHistory(),
+		status:      newStatusBar(),
+		dbErr:       dbErr,
+		backendErr:  backendErr,
+		opts:        opts,
+		session:     sess,
+		runner: actions.Runner{
+			Policy: opts.Policy,
+			Chroot: chroot,
+			DryRun: opts.DryRun,
+		},
 	}
 }
 
 func (m *uimodel) Init() tea.Cmd {
-	return textarea.Blink
+	return tea.Batch(textarea.Blink, m.checkHealth(0))
 }
 
 func (m *uimodel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -112,82 +236,395 @@ func (m *uimodel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.viewport.Width = msg.Width
 		m.textarea.SetWidth(msg.Width)
-		m.viewport.Height = msg.Height - m.textarea.Height() - lipgloss.Height(gap)
+		m.height = msg.Height
+		m.layout()
+		m.answers.setWidth(msg.Width)
+		for i := range m.messages {
+			m.messages[i].rendered = ""
+		}
 
-		if len(m.inputs) > 0 {
+		if len(m.messages) > 0 {
 			// Wrap content before setting it.
-			m.viewport.SetContent(lipgloss.NewStyle().Width(m.viewport.Width).Render(strings.Join(m.inputs, "\n")))
+			m.viewport.SetContent(m.render())
 		}
 		m.viewport.GotoBottom()
 	case tea.KeyMsg:
+		if m.review != nil && msg.Type != tea.KeyCtrlC {
+			cmd := m.updateReview(msg)
+			m.viewport.SetContent(m.render())
+			m.viewport.GotoBottom()
+			return m, cmd
+		}
+		if m.inputs.searching {
+			return m, m.updateSearch(msg)
+		}
 		switch msg.Type {
-		case tea.KeyCtrlC, tea.KeyEsc:
-			fmt.Println(m.textarea.Value())
+		case tea.KeyCtrlR:
+			m.inputs.startSearch()
+			return m, nil
+		case tea.KeyUp:
+			if m.textarea.Line() == 0 {
+				if entry, ok := m.inputs.prev(m.textarea.Value()); ok {
+					m.setInput(entry)
+				}
+				return m, nil
+			}
+		case tea.KeyDown:
+			if m.textarea.Line() == m.textarea.LineCount()-1 {
+				if entry, ok := m.inputs.next(); ok {
+					m.setInput(entry)
+				}
+				return m, nil
+			}
+		case tea.KeyCtrlX:
+			m.interrupt()
+		case tea.KeyEsc:
+			if m.isRunning {
+				m.interrupt()
+			} else {
+				return m, tea.Quit
+			}
+		case tea.KeyCtrlC:
+			if m.isRunning {
+				m.interrupt()
+				// the answer is saved with the session on exit
+				if last := &m.messages[len(m.messages)-1]; last.sender == "Kowalski" {
+					last.interrupted = true
+				}
+			}
 			return m, tea.Quit
+		case tea.KeyCtrlO:
+			m.showSources = !m.showSources
+			m.viewport.SetContent(m.render())
 		case tea.KeyEnter:
-			if !m.isRunning {
-				m.inputs = append(m.inputs, m.senderStyle.Render(m.uid+": ")+m.textarea.Value())
-				m.answer = "Kowalski: "
-				m.viewport.SetContent(lipgloss.NewStyle().Width(m.viewport.Width).Render(
-					strings.Join(m.inputs, "\n")))
-				m.TalkLLMBackground(m.textarea.Value())
+			if !m.isRunning && !msg.Alt && strings.TrimSpace(m.textarea.Value()) != "" {
+				input := m.textarea.Value()
+				m.inputs.add(input)
+				m.messages = append(m.messages, chatMessage{sender: m.uid, text: input})
+				isCommand, cmd := m.command(input)
+				if !isCommand {
+					m.TalkLLMBackground(input)
+					cmd = m.status.spinner.Tick
+				}
+				m.viewport.SetContent(m.render())
 				m.textarea.Reset()
+				m.resizeInput()
 				m.viewport.GotoBottom()
+				return m, cmd
 			}
 		}
+	case contextMsg:
+		if log.GetLevel() <= log.DebugLevel && len(msg.queries) > 0 {
+			m.messages = append(m.messages, chatMessage{sender: "Search",
+				text: strings.Join(msg.queries, "; ")})
+		}
+		m.lastPrompt = msg.prompt.Prompt
+		m.status.phase = phaseGeneration
+		m.status.retrieved(msg.prompt.Sources)
+		m.messages = append(m.messages, chatMessage{sender: "Kowalski", sources: msg.prompt.Sources})
+		m.viewport.SetContent(m.render())
+		m.viewport.GotoBottom()
+	case toolStepMsg:
+		m.messages = append(m.messages, chatMessage{sender: "Tool", text: msg.String()})
+		m.viewport.SetContent(m.render())
+		m.viewport.GotoBottom()
 	case LLMAns:
-		m.answer += string(msg)
-		m.viewport.SetContent(lipgloss.NewStyle().Width(m.viewport.Width).Render(
-			strings.Join(append(m.inputs, m.answer), "\n")))
+		m.messages[len(m.messages)-1].text += string(msg)
+		m.messages[len(m.messages)-1].rendered = ""
+		m.viewport.SetContent(m.render())
+		m.viewport.GotoBottom()
+	case spinner.TickMsg:
+		// stops ticking when nothing runs
+		if m.status.phase != phaseIdle {
+			var cmd tea.Cmd
+			m.status.spinner, cmd = m.status.spinner.Update(msg)
+			return m, cmd
+		}
+		return m, nil
+	case healthMsg:
+		cmd := m.updateHealth(msg)
+		m.layout()
+		m.viewport.SetContent(m.render())
+		m.viewport.GotoBottom()
+		return m, cmd
+	case pullMsg:
+		m.updatePull(msg)
+	case modelMsg:
+		m.modelFound(msg)
+		m.viewport.SetContent(m.render())
 		m.viewport.GotoBottom()
+	case pullDoneMsg:
+		m.pullDone(msg)
+		m.viewport.SetContent(m.render())
+		m.viewport.GotoBottom()
+	case statsMsg:
+		m.status.stats = msg.stats
+		m.status.contextSize = msg.contextSize
+	case llmDone:
+		m.isRunning = false
+		m.cancel = nil
+		m.status.phase = phaseIdle
+		m.err = nil
+		answer := &m.messages[len(m.messages)-1]
+		answer.plan = actions.Parse(answer.text)
+		answer.interrupted = msg.interrupted
+		m.history = append(m.history, database.Turn{
+			Question: msg.question,
+			Answer:   answer.text,
+			Results:  msg.results,
+		})
+		m.auditAnswer(msg.question, *answer)
+		m.saveSession()
+		// the actions of an interrupted answer may be incomplete
+		if !answer.interrupted {
+			m.startReview(answer.plan)
+		}
+		m.viewport.SetContent(m.render())
+		m.viewport.GotoBottom()
+	case editedMsg:
+		if m.review != nil {
+			var cmd tea.Cmd
+			if msg.err != nil {
+				m.messages = append(m.messages, chatMessage{sender: "Review", text: "editor failed: " + msg.err.Error()})
+				cmd = m.showReview(m.review.content)
+			} else if m.current().Type == actions.CommandAction {
+				cmd = m.showReview(strings.TrimSpace(msg.content))
+			} else {
+				cmd = m.showReview(msg.content)
+			}
+			m.viewport.SetContent(m.render())
+			m.viewport.GotoBottom()
+			return m, cmd
+		}
+	case commandDoneMsg:
+		if m.review != nil {
+			cmd := m.commandDone(msg)
+			m.viewport.SetContent(m.render())
+			m.viewport.GotoBottom()
+			return m, cmd
+		}
 
 	// We handle errors just like any other message
 	case errMsg:
 		m.err = msg
-		return m, nil
+		m.isRunning = false
+		m.cancel = nil
+		m.status.phase = phaseIdle
+		text := "An error occured: " + msg.Error()
+		// replace the empty answer created for the sources
+		if n := len(m.messages); n > 0 && m.messages[n-1].sender == "Kowalski" && m.messages[n-1].text == "" {
+			m.messages[n-1].text = text
+		} else {
+			m.messages = append(m.messages, chatMessage{sender: "Kowalski", text: text})
+		}
+		var cmd tea.Cmd
+		// the error may be caused by ollama or the server being down
+		if !m.checking {
+			cmd = m.checkHealth(0)
+		}
+		m.layout()
+		m.viewport.SetContent(m.render())
+		m.viewport.GotoBottom()
+		return m, cmd
 	}
 
 	m.textarea, tiCmd = m.textarea.Update(msg)
+	m.resizeInput()
+	if m.bannerText() != m.shownBanner {
+		m.layout()
+	}
 	m.viewport, vpCmd = m.viewport.Update(msg)
 
 	return m, tea.Batch(tiCmd, vpCmd)
 }
 
 func (m *uimodel) View() string {
+	input := m.textarea.View()
+	if m.inputs.searching {
+		input = m.planStyle.Height(m.textarea.Height()).Render(m.inputs.searchPrompt())
+	}
+	if m.review != nil {
+		input = m.planStyle.Render(m.reviewHelp())
+	}
 	return fmt.Sprintf(
-		"%s%s%s",
+		"%s%s\n%s\n%s",
+		m.banner(),
 		m.viewport.View(),
-		gap,
-		m.textarea.View(),
+		m.statusLine(),
+		input,
 	)
 }
 
+// render all messages of the chat, wrapped to the viewport
+func (m *uimodel) render() string {
+	var out []string
+	for i := range m.messages {
+		msg := &m.messages[i]
+		if msg.sender == "Kowalski" && !m.answers.plain {
+			if msg.rendered == "" {
+				msg.rendered = m.answers.render(msg.text)
+			}
+			out = append(out, m.senderStyle.Render(msg.sender+":"), msg.rendered)
+		} else if msg.sender == "Kowalski" {
+			out = append(out, m.senderStyle.Render(msg.sender+": ")+m.answers.render(msg.text))
+		} else {
+			out = append(out, m.senderStyle.Render(msg.sender+": ")+msg.text)
+		}
+		if msg.interrupted {
+			out = append(out, m.errStyle.Render("[interrupted]"))
+		}
+		if !msg.plan.Empty() {
+			out = append(out, m.renderPlan(msg.plan))
+		}
+		if len(msg.sources) > 0 {
+			out = append(out, m.sourceStyle.Render(m.renderSources(msg.sources)))
+		}
+	}
+	return lipgloss.NewStyle().Width(m.viewport.Width).Render(strings.Join(out, "\n"))
+}
+
+// footer with the documents an answer is based on
+func (m *uimodel) renderSources(sources []database.Source) string {
+	if !m.showSources {
+		return fmt.Sprintf("▸ Sources (%d)", len(sources))
+	}
+	out := []string{"▾ Sources:"}
+	for i, src := range sources {
+		out = append(out, fmt.Sprintf("  [%d] %s (%s, dist: %.3f)", i+1, src.Title, src.Source, src.Dist))
+	}
+	return strings.Join(out, "\n")
+}
+
+// list the actions proposed in an answer
+func (m *uimodel) renderPlan(plan actions.Plan) string {
+	out := []string{"Proposed actions:"}
+	for _, ref := range plan.Order {
+		switch ref.Type {
+		case actions.CommandAction:
+			cmd := plan.Commands[ref.Index]
+			out = append(out, fmt.Sprintf("  [c%d] $ %s", ref.Index+1, cmd.Command))
+		case actions.FileAction:
+			file := plan.Files[ref.Index]
+			out = append(out, fmt.Sprintf("  [f%d] write %s (%d lines)", ref.Index+1, file.Path,
+				strings.Count(file.Content, "\n")))
+		}
+	}
+	return m.planStyle.Render(strings.Join(out, "\n"))
+}
+
 type LLMAns string
 
+// send when the answer of the LLM is complete or was interrupted
+type llmDone struct {
+	question    string
+	interrupted bool
+	// the question are the results of the commands
+	results bool
+}
+
+// send when the prompt for the LLM is ready
+type contextMsg struct {
+	prompt  database.PromptContext
+	queries []string
+}
+
 func (m *uimodel) TalkLLMBackground(msg string) error {
+	return m.talk(msg, false)
+}
+
+/*
+Send the results of the commands to the LLM. They aren't a question, so
+nothing is searched and they are given as input for the last question.
+*/
+func (m *uimodel) talkResults(results string) error {
+	return m.talk(results, true)
+}
+
+func (m *uimodel) talk(msg string, results bool) error {
 	if m.isRunning {
 		return nil
 	}
-	m.mutex.Lock()
 	m.isRunning = true
-	m.mutex.Unlock()
-	prompt, err := m.db.GetContext(msg, []string{}, m.location, m.ollama.GetContextSize())
-	if err != nil {
-		m.err = err
-		fmt.Println("An errror occured", err)
+	m.status.phase = phaseRetrieval
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	history := m.history[max(0, len(m.history)-historyTurns):]
+	opts := m.opts
+	collections := m.collections
+	var retriever database.Retriever = m.backend
+	if !m.retrieval() || results {
+		retriever = noRetrieval{}
+	}
+	task, input := msg, ""
+	if results {
+		task, input = "", msg
+		// the question the commands were proposed for
+		for _, turn := range history {
+			if !turn.Results {
+				task = turn.Question
+			}
+		}
+	}
+	if opts.Agent {
+		kwAgent, box := m.newAgent()
+		go talkAgent(ctx, kwAgent, box, msg, history)
 		return nil
 	}
-	ch := make(chan *ollamaconnector.TaskResponse)
-	go m.ollama.SendTaskStream(prompt, ch)
 	go func() {
+		var queries []string
+		if opts.Rewrite && len(history) > 0 && !results {
+			var err error
+			queries, err = database.RewriteQuery(ctx, m.backend, history, msg, opts.MultiQuery)
+			if err != nil && ctx.Err() == nil {
+				log.Warnf("couldn't rewrite question: %s", err)
+			}
+			if err != nil {
+				queries = nil
+			}
+		}
+		if ctx.Err() != nil {
+			uiProc.Send(contextMsg{})
+			uiProc.Send(llmDone{question: msg, interrupted: true, results: results})
+			return
+		}
+		if len(collections) == 0 {
+			collections = m.backend.ListCollections()
+		}
+		prompt, err := database.BuildContext(retriever, task, collections, m.location, m.backend.GetContextSize(),
+			database.OptionWithHistory(history), database.OptionWithQueries(queries), database.OptionWithInput(input))
+		if err != nil {
+			uiProc.Send(errMsg(err))
+			return
+		}
+		uiProc.Send(contextMsg{prompt: prompt, queries: queries})
+		ch := make(chan *ollamaconnector.TaskResponse)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- m.backend.SendTaskStream(ctx, prompt.Prompt, ch)
+		}()
 		for resp := range ch {
 			uiProc.Send(LLMAns(resp.Response))
+			if resp.Done {
+				uiProc.Send(statsMsg{stats: resp.Stats(), contextSize: m.backend.GetContextSize()})
+			}
 		}
-		m.mutex.Lock()
-		m.inputs = append(m.inputs, m.answer)
-		m.isRunning = false
-		m.answer = ""
-		m.mutex.Unlock()
+		err = <-errCh
+		if ctx.Err() != nil {
+			uiProc.Send(llmDone{question: msg, interrupted: true, results: results})
+			return
+		}
+		if err != nil {
+			uiProc.Send(errMsg(err))
+			return
+		}
+		uiProc.Send(llmDone{question: msg, results: results})
 	}()
 	return nil
 }
+
+// stop the running generation, the partial answer is kept
+func (m *uimodel) interrupt() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+}
@@ -0,0 +1,304 @@
+package chat
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/muesli/termenv"
+	"github.com/openSUSE/kowalski/internal/pkg/actions"
+	"github.com/openSUSE/kowalski/internal/pkg/database"
+	"github.com/openSUSE/kowalski/internal/pkg/file"
+	"github.com/openSUSE/kowalski/internal/pkg/session"
+	"github.com/openSUSE/kowalski/internal/pkg/templates"
+)
+
+// command which can be typed into the chat
+type chatCommand struct {
+	name  string
+	usage string
+	help  string
+	// a returned command runs in the background, like looking up a modell
+	run func(m *uimodel, arg string) tea.Cmd
+}
+
+// for the commands which are done at once
+func direct(run func(m *uimodel, arg string)) func(m *uimodel, arg string) tea.Cmd {
+	return func(m *uimodel, arg string) tea.Cmd {
+		run(m, arg)
+		return nil
+	}
+}
+
+// set in init, as /help refers to the list
+var chatCommands []chatCommand
+
+func init() {
+	chatCommands = []chatCommand{
+		{"/help", "", "show this help", direct((*uimodel).showHelp)},
+		{"/model", "[NAME]", "show or switch the modell answering", (*uimodel).switchModel},
+		{"/collections", "[NAME...|all]", "show or choose the collections which are searched", direct((*uimodel).selectCollections)},
+		{"/context", "", "show the prompt sent last to the modell", direct((*uimodel).showContext)},
+		{"/sources", "", "list the sources of the last answer", direct((*uimodel).listSources)},
+		{"/source", "N", "show source N of the last answer", direct((*uimodel).showSource)},
+		{"/copy", "N", "copy command N of the last answer to the clipboard", direct((*uimodel).copyCommand)},
+		{"/rewrite", "[on|off]", "rewrite follow up questions before searching", direct((*uimodel).switchRewrite)},
+		{"/location", "[PATH]", "show or change the root of the files presented to the modell", direct((*uimodel).changeLocation)},
+		{"/save", "[FILE]", "save the session, or export it as markdown to FILE", direct((*uimodel).save)},
+		{"/clear", "", "start a new session, the current one stays saved", direct((*uimodel).clear)},
+	}
+}
+
+/*
+Handle the chat commands, returns false if input isn't a command. Input
+like /etc/fstab is a path and not a command.
+*/
+func (m *uimodel) command(input string) (bool, tea.Cmd) {
+	cmd, arg, _ := strings.Cut(strings.TrimSpace(input), " ")
+	if !strings.HasPrefix(cmd, "/") || strings.Contains(cmd[1:], "/") {
+		return false, nil
+	}
+	arg = strings.TrimSpace(arg)
+	for _, chatCmd := range chatCommands {
+		if chatCmd.name == cmd {
+			return true, chatCmd.run(m, arg)
+		}
+	}
+	m.reply(fmt.Sprintf("unknown command %s, see /help", cmd))
+	return true, nil
+}
+
+// answer of a command
+func (m *uimodel) reply(text string) {
+	m.messages = append(m.messages, chatMessage{sender: "Kowalski", text: text})
+}
+
+func (m *uimodel) showHelp(string) {
+	out := []string{"Commands:"}
+	for _, chatCmd := range chatCommands {
+		out = append(out, fmt.Sprintf("- `%s` %s", strings.TrimSpace(chatCmd.name+" "+chatCmd.usage), chatCmd.help))
+	}
+	out = append(out, "", "Keys: Alt-Enter inserts a newline, Up/Down browse and Ctrl-R searches the input history, Ctrl-X or Esc stops an answer, Ctrl-O toggles the sources, Ctrl-C quits.")
+	m.reply(strings.Join(out, "\n"))
+}
+
+// result of the lookup of the modell for /model
+type modelMsg struct {
+	name  string
+	found bool
+	err   error
+}
+
+func (m *uimodel) switchModel(arg string) tea.Cmd {
+	if arg == "" {
+		m.reply("modell: " + m.model)
+		return nil
+	}
+	if m.opts.Server != "" {
+		m.reply("the modell is chosen by the server")
+		return nil
+	}
+	// the running answer uses the settings and templates of the modell
+	if m.isRunning {
+		m.reply("wait until the answer is done or stop it with ctrl+x")
+		return nil
+	}
+	// ollama may be slow to answer, so don't block the ui
+	llm := *m.ollama
+	return func() tea.Msg {
+		found, err := llm.FindModel(arg)
+		return modelMsg{name: arg, found: found, err: err}
+	}
+}
+
+func (m *uimodel) modelFound(msg modelMsg) {
+	if msg.err != nil {
+		m.reply(fmt.Sprintf("couldn't look up %s: %s", msg.name, msg.err))
+		return
+	}
+	// a question may have been asked during the lookup
+	if m.isRunning {
+		m.reply("wait until the answer is done or stop it with ctrl+x")
+		return
+	}
+	arg := msg.name
+	m.ollama.SetModel(arg)
+	// the modell may have its own templates
+	templates.Model = arg
+	m.model = arg
+	m.session.Model = arg
+	m.reply("switched to " + arg)
+	if !msg.found {
+		m.startPull(arg)
+	}
+}
+
+func (m *uimodel) selectCollections(arg string) {
+	available := m.backend.ListCollections()
+	sort.Strings(available)
+	switch arg {
+	case "":
+	case "all":
+		m.collections = nil
+	default:
+		selected := strings.Fields(arg)
+		for _, name := range selected {
+			if !slices.Contains(available, name) {
+				m.reply(fmt.Sprintf("unknown collection %s, available: %s", name, strings.Join(available, ", ")))
+				return
+			}
+		}
+		m.collections = selected
+	}
+	out := []string{"Collections:"}
+	for _, name := range available {
+		mark := " "
+		if len(m.collections) == 0 || slices.Contains(m.collections, name) {
+			mark = "x"
+		}
+		out = append(out, fmt.Sprintf("- [%s] %s", mark, name))
+	}
+	m.reply(strings.Join(out, "\n"))
+}
+
+func (m *uimodel) showContext(string) {
+	if m.lastPrompt == "" {
+		m.reply("no prompt was sent yet")
+		return
+	}
+	m.messages = append(m.messages, chatMessage{sender: "Prompt", text: "\n" + m.lastPrompt})
+}
+
+// the sources of the last answer which has some
+func (m *uimodel) lastSources() []database.Source {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if len(m.messages[i].sources) > 0 {
+			return m.messages[i].sources
+		}
+	}
+	return nil
+}
+
+func (m *uimodel) listSources(string) {
+	sources := m.lastSources()
+	if len(sources) == 0 {
+		m.reply("no sources")
+		return
+	}
+	out := []string{"Sources:"}
+	for i, src := range sources {
+		out = append(out, fmt.Sprintf("%d. %s (%s, dist: %.3f)", i+1, src.Title, src.Source, src.Dist))
+	}
+	m.reply(strings.Join(out, "\n"))
+}
+
+// show the full section of source N of the last answer
+func (m *uimodel) showSource(arg string) {
+	sources := m.lastSources()
+	nr, err := strconv.Atoi(arg)
+	if err != nil || nr < 1 || nr > len(sources) {
+		m.reply(fmt.Sprintf("usage: /source N with N between 1 and %d", len(sources)))
+		return
+	}
+	src := sources[nr-1]
+	sec, err := m.backend.GetSection(src.Hash, src.Index)
+	if err != nil {
+		m.reply(err.Error())
+		return
+	}
+	// same template as used for the full output of 'database get'
+	str, err := sec.Render(templates.Get(templates.RenderInfoWithMetaName), map[string]func(string) string{
+		"RenderFile": func(input string) string { return m.location.Get(input) },
+	})
+	if err != nil {
+		str = err.Error()
+	}
+	m.messages = append(m.messages, chatMessage{
+		sender: fmt.Sprintf("Source %d", nr),
+		text:   fmt.Sprintf("%s\n%s", src.Source, str),
+	})
+}
+
+// copy command N of the last answer to the clipboard of the terminal
+func (m *uimodel) copyCommand(arg string) {
+	var commands []actions.Command
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if len(m.messages[i].plan.Commands) > 0 {
+			commands = m.messages[i].plan.Commands
+			break
+		}
+	}
+	nr, err := strconv.Atoi(strings.TrimPrefix(arg, "c"))
+	if err != nil || nr < 1 || nr > len(commands) {
+		m.reply(fmt.Sprintf("usage: /copy N with N between 1 and %d", len(commands)))
+		return
+	}
+	// OSC 52, which works as well over ssh
+	termenv.Copy(commands[nr-1].Command)
+	m.reply(fmt.Sprintf("copied: %s", commands[nr-1].Command))
+}
+
+func (m *uimodel) switchRewrite(arg string) {
+	switch arg {
+	case "on":
+		m.opts.Rewrite = true
+	case "off":
+		m.opts.Rewrite = false
+	default:
+		m.opts.Rewrite = !m.opts.Rewrite
+	}
+	m.reply(fmt.Sprintf("query rewriting: %v", m.opts.Rewrite))
+}
+
+func (m *uimodel) changeLocation(arg string) {
+	if arg == "" {
+		m.reply(fmt.Sprintf("location: %q", m.runner.Chroot))
+		return
+	}
+	// the running answer reads the files of the location
+	if m.isRunning {
+		m.reply("wait until the answer is done or stop it with ctrl+x")
+		return
+	}
+	path, err := filepath.Abs(arg)
+	if err == nil {
+		var stat os.FileInfo
+		if stat, err = os.Stat(path); err == nil && !stat.IsDir() {
+			err = fmt.Errorf("%s is not a directory", path)
+		}
+	}
+	if err != nil {
+		m.reply(err.Error())
+		return
+	}
+	m.location = file.Local{Chroot: path}
+	m.runner.Chroot = path
+	m.reply("location: " + path)
+}
+
+func (m *uimodel) save(arg string) {
+	m.saveSession()
+	if arg == "" {
+		m.reply("saved session " + m.session.Id)
+		return
+	}
+	if err := os.WriteFile(arg, []byte(m.session.Markdown()), 0600); err != nil {
+		m.reply(err.Error())
+		return
+	}
+	m.reply("exported session to " + arg)
+}
+
+func (m *uimodel) clear(string) {
+	m.saveSession()
+	m.messages = []chatMessage{}
+	m.history = nil
+	m.lastPrompt = ""
+	m.session = session.New(m.model)
+	m.viewport.SetContent("")
+}
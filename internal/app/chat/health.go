@@ -0,0 +1,135 @@
+package chat
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// how often ollama or the server is contacted while it's down
+const retryInterval = 5 * time.Second
+
+// result of the check if the backend can be reached
+type healthMsg struct {
+	err   error
+	model string
+	// the modell of the local ollama has to be pulled
+	missing bool
+}
+
+// check the backend after delay, immediately for a delay of zero
+func (m *uimodel) checkHealth(delay time.Duration) tea.Cmd {
+	m.checking = true
+	backend := m.backend
+	// read here, as /model may change it while the check runs
+	llm := m.ollama.LLM
+	ping := func() tea.Msg {
+		if local, ok := backend.(localBackend); ok {
+			found, err := local.FindModel(llm)
+			return healthMsg{err: err, missing: err == nil && !found}
+		}
+		err := backend.Ping()
+		msg := healthMsg{err: err}
+		if model, ok := backend.(interface{ Model() string }); ok && err == nil {
+			msg.model = model.Model()
+		}
+		return msg
+	}
+	if delay == 0 {
+		return ping
+	}
+	return tea.Tick(delay, func(time.Time) tea.Msg { return ping() })
+}
+
+// keep retrying until the backend is back
+func (m *uimodel) updateHealth(msg healthMsg) tea.Cmd {
+	m.checking = false
+	if msg.err != nil {
+		m.backendErr = msg.err
+		return m.checkHealth(retryInterval)
+	}
+	if m.backendErr != nil {
+		m.backendErr = nil
+		m.err = nil
+		// the chunks of a running answer are appended to the last message
+		if !m.isRunning {
+			m.reply("connection is back, ask again with Up and Enter")
+		}
+	}
+	if m.model == "" {
+		m.model = msg.model
+		m.session.Model = msg.model
+	}
+	if msg.missing {
+		m.startPull(m.ollama.LLM)
+	}
+	return nil
+}
+
+// where the answers come from, for the error messages
+func (m *uimodel) backendName() string {
+	if m.opts.Server != "" {
+		return "server " + m.opts.Server
+	}
+	return "ollama at " + m.ollama.OllamaURL
+}
+
+// problems shown above the messages, empty if everything is fine
+func (m *uimodel) bannerText() string {
+	var out []string
+	if m.backendErr != nil {
+		out = append(out, fmt.Sprintf("%s can't be reached, retrying every %s: %s",
+			m.backendName(), retryInterval, m.backendErr))
+	} else if m.err != nil {
+		out = append(out, "error: "+m.err.Error())
+	}
+	if !m.retrieval() {
+		reason := "no collections in the database"
+		if m.dbErr != nil {
+			reason = "no knowledge database: " + m.dbErr.Error()
+		}
+		out = append(out, reason+", answering without retrieval")
+	}
+	return strings.Join(out, " │ ")
+}
+
+func (m *uimodel) banner() string {
+	text := m.bannerText()
+	if text == "" {
+		return ""
+	}
+	return m.errStyle.Width(m.viewport.Width).MaxHeight(1).Render(text) + "\n"
+}
+
+// search the documents only if there are some
+func (m *uimodel) retrieval() bool {
+	if m.opts.Server != "" {
+		return true
+	}
+	return m.dbErr == nil && len(m.backend.ListCollections()) > 0
+}
+
+// give the viewport all lines not used by the banner, the status and the input
+func (m *uimodel) layout() {
+	m.shownBanner = m.bannerText()
+	if m.height == 0 {
+		return
+	}
+	m.viewport.Height = m.height - m.textarea.Height() - lipgloss.Height(gap)
+	if m.shownBanner != "" {
+		m.viewport.Height--
+	}
+	m.viewport.GotoBottom()
+}
+
+func openLog() (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(logFile), 0700); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+}
@@ -0,0 +1,219 @@
+package chat
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+	"github.com/openSUSE/kowalski/internal/pkg/xdg"
+)
+
+// file with the inputs of all chats, one json string per line so that
+// multiline inputs survive
+var historyFile = xdg.StateHome("history")
+
+// number of inputs which are kept
+const maxInputs = 1000
+
+// the textarea grows with the input up to maxInputHeight lines
+const (
+	minInputHeight = 3
+	maxInputHeight = 10
+)
+
+/*
+Inputs typed into the chat, browsed with up/down and searched with
+Ctrl-R like in a shell.
+*/
+type inputHistory struct {
+	entries []string
+	// entry shown in the textarea, len(entries) if a new input is edited
+	pos int
+	// the new input, kept while browsing
+	draft string
+	// reverse search
+	searching bool
+	query     string
+	match     int
+}
+
+func loadHistory() *inputHistory {
+	hist := &inputHistory{}
+	fh, err := os.Open(historyFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("couldn't read input history: %s", err)
+		}
+		return hist
+	}
+	defer fh.Close()
+	scanner := bufio.NewScanner(fh)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		var entry string
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			hist.entries = append(hist.entries, entry)
+		}
+	}
+	if len(hist.entries) > 2*maxInputs {
+		hist.entries = hist.entries[len(hist.entries)-maxInputs:]
+		hist.rewrite()
+	}
+	hist.pos = len(hist.entries)
+	return hist
+}
+
+// write the kept entries, so that the file doesn't grow forever
+func (hist *inputHistory) rewrite() {
+	var out strings.Builder
+	for _, entry := range hist.entries {
+		line, _ := json.Marshal(entry)
+		out.Write(line)
+		out.WriteString("\n")
+	}
+	tmp := historyFile + ".tmp"
+	if err := os.WriteFile(tmp, []byte(out.String()), 0600); err != nil {
+		log.Warnf("couldn't write input history: %s", err)
+		return
+	}
+	os.Rename(tmp, historyFile)
+}
+
+// add an input and append it to the history file
+func (hist *inputHistory) add(input string) {
+	hist.pos = len(hist.entries)
+	hist.draft = ""
+	if strings.TrimSpace(input) == "" {
+		return
+	}
+	if len(hist.entries) > 0 && hist.entries[len(hist.entries)-1] == input {
+		return
+	}
+	hist.entries = append(hist.entries, input)
+	if len(hist.entries) > maxInputs {
+		hist.entries = hist.entries[len(hist.entries)-maxInputs:]
+	}
+	hist.pos = len(hist.entries)
+	if err := os.MkdirAll(filepath.Dir(historyFile), 0700); err != nil {
+		log.Warnf("couldn't write input history: %s", err)
+		return
+	}
+	fh, err := os.OpenFile(historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Warnf("couldn't write input history: %s", err)
+		return
+	}
+	defer fh.Close()
+	line, _ := json.Marshal(input)
+	fh.Write(append(line, '\n'))
+}
+
+// older entry, current is the edited input which is kept as draft
+func (hist *inputHistory) prev(current string) (string, bool) {
+	if hist.pos == 0 {
+		return "", false
+	}
+	if hist.pos == len(hist.entries) {
+		hist.draft = current
+	}
+	hist.pos--
+	return hist.entries[hist.pos], true
+}
+
+// newer entry, or the draft when the end is reached
+func (hist *inputHistory) next() (string, bool) {
+	if hist.pos >= len(hist.entries) {
+		return "", false
+	}
+	hist.pos++
+	if hist.pos == len(hist.entries) {
+		return hist.draft, true
+	}
+	return hist.entries[hist.pos], true
+}
+
+func (hist *inputHistory) startSearch() {
+	hist.searching = true
+	hist.query = ""
+	hist.match = len(hist.entries)
+}
+
+/*
+Search backwards for query starting below the given position, returns false
+if nothing matches.
+*/
+func (hist *inputHistory) search(from int) bool {
+	for i := min(from, len(hist.entries)) - 1; i >= 0; i-- {
+		if strings.Contains(hist.entries[i], hist.query) {
+			hist.match = i
+			return true
+		}
+	}
+	return false
+}
+
+// the entry found by the reverse search
+func (hist *inputHistory) found() string {
+	if hist.match < len(hist.entries) {
+		return hist.entries[hist.match]
+	}
+	return ""
+}
+
+// prompt shown instead of the textarea during the search
+func (hist *inputHistory) searchPrompt() string {
+	return "(reverse-i-search)`" + hist.query + "': " + strings.ReplaceAll(hist.found(), "\n", "⏎")
+}
+
+// replace the input, the cursor is placed at the end
+func (m *uimodel) setInput(input string) {
+	m.textarea.SetValue(input)
+	m.resizeInput()
+}
+
+// fit the textarea to the input and give the rest to the viewport
+func (m *uimodel) resizeInput() {
+	height := min(max(m.textarea.LineCount(), minInputHeight), maxInputHeight)
+	if height == m.textarea.Height() {
+		return
+	}
+	m.textarea.SetHeight(height)
+	m.layout()
+}
+
+// keys during the reverse search, enter takes the match for editing
+func (m *uimodel) updateSearch(msg tea.KeyMsg) tea.Cmd {
+	hist := m.inputs
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		hist.searching = false
+		return tea.Quit
+	case tea.KeyEsc, tea.KeyCtrlG:
+		hist.searching = false
+	case tea.KeyEnter:
+		hist.searching = false
+		if found := hist.found(); found != "" {
+			hist.pos = hist.match
+			m.setInput(found)
+		}
+	case tea.KeyCtrlR:
+		hist.search(hist.match)
+	case tea.KeyBackspace:
+		if len(hist.query) > 0 {
+			runes := []rune(hist.query)
+			hist.query = string(runes[:len(runes)-1])
+			hist.match = len(hist.entries)
+			hist.search(hist.match)
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		hist.query += string(msg.Runes)
+		if !hist.search(hist.match + 1) {
+			hist.match = len(hist.entries)
+		}
+	}
+	return nil
+}
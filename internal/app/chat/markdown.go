@@ -0,0 +1,79 @@
+package chat
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/glamour/styles"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/log"
+	"github.com/openSUSE/kowalski/internal/pkg/actions"
+)
+
+/*
+Renders the answers of the LLM as markdown, the actions are shown as
+numbered code blocks. Dumb terminals and NO_COLOR get plain text.
+*/
+type answerRenderer struct {
+	plain bool
+	style string
+	width int
+	md    *glamour.TermRenderer
+}
+
+func newAnswerRenderer() *answerRenderer {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return &answerRenderer{plain: true}
+	}
+	style := styles.LightStyle
+	if lipgloss.HasDarkBackground() {
+		style = styles.DarkStyle
+	}
+	return &answerRenderer{style: style}
+}
+
+// the renderer has to be recreated if the width changes
+func (rend *answerRenderer) setWidth(width int) {
+	if rend.plain || width == rend.width {
+		return
+	}
+	rend.width = width
+	md, err := glamour.NewTermRenderer(
+		glamour.WithStandardStyle(rend.style),
+		glamour.WithWordWrap(width),
+	)
+	if err != nil {
+		log.Warnf("couldn't create markdown renderer: %s", err)
+		md = nil
+	}
+	rend.md = md
+}
+
+// render the answer, which may still be incomplete
+func (rend *answerRenderer) render(answer string) string {
+	plan := actions.Parse(answer)
+	if rend.plain || rend.md == nil {
+		return actions.Replace(answer, plan, func(ref actions.ActionRef) string {
+			if ref.Type == actions.CommandAction {
+				return fmt.Sprintf("\n[c%d] $ %s\n", ref.Index+1, plan.Commands[ref.Index].Command)
+			}
+			file := plan.Files[ref.Index]
+			return fmt.Sprintf("\n[f%d] %s:\n%s", ref.Index+1, file.Path, file.Content)
+		})
+	}
+	text := actions.Replace(answer, plan, func(ref actions.ActionRef) string {
+		if ref.Type == actions.CommandAction {
+			return fmt.Sprintf("\n\n**[c%d]**\n```sh\n%s\n```\n\n", ref.Index+1, plan.Commands[ref.Index].Command)
+		}
+		file := plan.Files[ref.Index]
+		return fmt.Sprintf("\n\n**[f%d]** `%s`\n```\n%s```\n\n", ref.Index+1, file.Path, file.Content)
+	})
+	out, err := rend.md.Render(text)
+	if err != nil {
+		log.Warnf("couldn't render markdown: %s", err)
+		return answer
+	}
+	return strings.Trim(out, "\n")
+}
@@ -0,0 +1,83 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/openSUSE/kowalski/internal/app/ollamaconnector"
+)
+
+// progress of a modell which is pulled
+type pullMsg struct {
+	name     string
+	progress ollamaconnector.PullProgress
+}
+
+type pullDoneMsg struct {
+	name string
+	err  error
+}
+
+// shown in the status bar while a modell is pulled
+type pullState struct {
+	name     string
+	progress ollamaconnector.PullProgress
+	bar      progress.Model
+}
+
+/*
+Pull a missing modell in the background, so that the first question
+doesn't block without feedback.
+*/
+func (m *uimodel) startPull(name string) {
+	if m.pull != nil {
+		return
+	}
+	if m.ollama.NoAutoPull {
+		m.err = fmt.Errorf("modell %s isn't present, pull it with 'kowalski model pull %s'", name, name)
+		return
+	}
+	m.pull = &pullState{
+		name: name,
+		bar:  progress.New(progress.WithDefaultGradient(), progress.WithWidth(30)),
+	}
+	llm := m.ollama
+	go func() {
+		ch := make(chan ollamaconnector.PullProgress)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- llm.Pull(context.Background(), name, ch)
+		}()
+		for prog := range ch {
+			uiProc.Send(pullMsg{name: name, progress: prog})
+		}
+		uiProc.Send(pullDoneMsg{name: name, err: <-errCh})
+	}()
+}
+
+func (m *uimodel) updatePull(msg pullMsg) {
+	if m.pull != nil && m.pull.name == msg.name {
+		m.pull.progress = msg.progress
+	}
+}
+
+func (m *uimodel) pullDone(msg pullDoneMsg) {
+	m.pull = nil
+	if msg.err != nil {
+		m.err = msg.err
+		return
+	}
+	// the chunks of a running answer are appended to the last message
+	if !m.isRunning {
+		m.reply(fmt.Sprintf("pulled %s", msg.name))
+	}
+}
+
+func (pull *pullState) View() string {
+	out := fmt.Sprintf("pulling %s: %s", pull.name, pull.progress.Status)
+	if pull.progress.Total > 0 {
+		out += " " + pull.bar.ViewAs(pull.progress.Fraction())
+	}
+	return out
+}
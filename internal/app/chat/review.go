@@ -0,0 +1,300 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/openSUSE/kowalski/internal/pkg/actions"
+	"github.com/openSUSE/kowalski/internal/pkg/audit"
+	"github.com/openSUSE/kowalski/internal/pkg/file"
+	"github.com/openSUSE/kowalski/internal/pkg/templates"
+)
+
+const (
+	fileHelp    = "[a]ccept [r]eject [e]dit [q]uit review"
+	commandHelp = "[y]es run [n]o skip [e]dit [q]uit review"
+)
+
+// the actions of an answer which are confirmed one by one by the user
+type actionReview struct {
+	plan    actions.Plan
+	refs    []actions.ActionRef
+	index   int
+	content string
+	running bool
+	// stops the running command
+	cancel  context.CancelFunc
+	results []actions.Result
+}
+
+// send when the editor for a proposed action was closed
+type editedMsg struct {
+	content string
+	err     error
+}
+
+// send when a confirmed command has finished
+type commandDoneMsg struct {
+	result actions.Result
+	err    error
+}
+
+// start the review of the proposed actions, incomplete ones are skipped
+func (m *uimodel) startReview(plan actions.Plan) {
+	review := actionReview{plan: plan}
+	for _, ref := range plan.Order {
+		switch ref.Type {
+		case actions.FileAction:
+			if plan.Files[ref.Index].Complete {
+				review.refs = append(review.refs, ref)
+			}
+		case actions.CommandAction:
+			if plan.Commands[ref.Index].Complete {
+				review.refs = append(review.refs, ref)
+			}
+		}
+	}
+	if len(review.refs) == 0 {
+		return
+	}
+	m.review = &review
+	m.textarea.Blur()
+	m.showReview(m.original())
+}
+
+func (m *uimodel) current() actions.ActionRef {
+	return m.review.refs[m.review.index]
+}
+
+// content of the current action as proposed by the LLM
+func (m *uimodel) original() string {
+	ref := m.current()
+	if ref.Type == actions.FileAction {
+		return m.review.plan.Files[ref.Index].Content
+	}
+	return m.review.plan.Commands[ref.Index].Command
+}
+
+func (m *uimodel) reviewHelp() string {
+	if m.review.running {
+		return "running... [ctrl+x] stop"
+	}
+	if m.current().Type == actions.FileAction {
+		return fileHelp
+	}
+	return commandHelp
+}
+
+// show the current action, files as diff against the content on the system
+func (m *uimodel) showReview(content string) tea.Cmd {
+	review := m.review
+	review.content = content
+	sender := fmt.Sprintf("Review %d/%d", review.index+1, len(review.refs))
+	ref := m.current()
+	if ref.Type == actions.CommandAction {
+		text := "run '" + content + "'?"
+		if err := m.runner.Check(content); err != nil {
+			m.audit(audit.Entry{
+				Type:    audit.CommandEntry,
+				Status:  audit.Denied,
+				Command: content,
+				Error:   err.Error(),
+			})
+			text = fmt.Sprintf("won't run '%s': %s", content, err)
+			m.messages = append(m.messages, chatMessage{sender: sender, text: text})
+			return m.nextReview()
+		}
+		if m.runner.DryRun {
+			text += " (dry run)"
+		}
+		m.messages = append(m.messages, chatMessage{sender: sender, text: text})
+		return nil
+	}
+	path := review.plan.Files[ref.Index].Path
+	orig, err := m.location.Read(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		m.messages = append(m.messages, chatMessage{sender: sender,
+			text: fmt.Sprintf("couldn't read %s: %s", path, err)})
+		return m.nextReview()
+	}
+	diff := actions.Diff(path, path, orig, content)
+	if diff == "" {
+		diff = "no changes"
+	} else if errors.Is(err, fs.ErrNotExist) {
+		diff = "new file\n" + diff
+	}
+	m.messages = append(m.messages, chatMessage{sender: sender, text: "\n" + diff})
+	return nil
+}
+
+// go to the next action, after the last one the results of the commands
+// are sent to the LLM
+func (m *uimodel) nextReview() tea.Cmd {
+	m.review.index++
+	if m.review.index < len(m.review.refs) {
+		return m.showReview(m.original())
+	}
+	results := m.review.results
+	m.review = nil
+	m.textarea.Focus()
+	if len(results) == 0 || m.runner.DryRun {
+		return nil
+	}
+	tmpl, err := template.New("results").Parse(templates.Get(templates.CommandResultsName))
+	if err != nil {
+		m.messages = append(m.messages, chatMessage{sender: "Kowalski", text: err.Error()})
+		return nil
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, results); err != nil {
+		m.messages = append(m.messages, chatMessage{sender: "Kowalski", text: err.Error()})
+		return nil
+	}
+	m.talkResults(buf.String())
+	return m.status.spinner.Tick
+}
+
+func (m *uimodel) updateReview(msg tea.KeyMsg) tea.Cmd {
+	if m.review.running {
+		switch msg.String() {
+		case "ctrl+x", "esc":
+			m.review.cancel()
+		}
+		return nil
+	}
+	ref := m.current()
+	content := m.review.content
+	switch msg.String() {
+	case "a", "y":
+		if ref.Type == actions.FileAction {
+			path := m.review.plan.Files[ref.Index].Path
+			m.messages = append(m.messages, chatMessage{sender: "Review", text: m.applyFile(path, content)})
+			return m.nextReview()
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		m.review.running = true
+		m.review.cancel = cancel
+		runner := m.runner
+		return func() tea.Msg {
+			defer cancel()
+			res, err := runner.Run(ctx, content)
+			return commandDoneMsg{result: res, err: err}
+		}
+	case "r", "n":
+		entry := audit.Entry{Status: audit.Rejected}
+		if ref.Type == actions.FileAction {
+			entry.Type = audit.FileEntry
+			entry.Path = m.review.plan.Files[ref.Index].Path
+			entry.After = audit.Checksum(content)
+		} else {
+			entry.Type = audit.CommandEntry
+			entry.Command = content
+		}
+		m.audit(entry)
+		m.messages = append(m.messages, chatMessage{sender: "Review", text: "skipped"})
+		return m.nextReview()
+	case "e":
+		return m.editAction(content)
+	case "q", "esc":
+		m.messages = append(m.messages, chatMessage{sender: "Review", text: "review aborted"})
+		m.review = nil
+		m.textarea.Focus()
+	}
+	return nil
+}
+
+// show the result of a command and go on with the review
+func (m *uimodel) commandDone(msg commandDoneMsg) tea.Cmd {
+	m.review.running = false
+	m.review.cancel = nil
+	res := msg.result
+	m.auditCommand(res, msg.err)
+	switch {
+	case errors.Is(msg.err, context.Canceled):
+		m.messages = append(m.messages, chatMessage{sender: "Review",
+			text: fmt.Sprintf("stopped '%s'", res.Command)})
+	case msg.err != nil:
+		m.messages = append(m.messages, chatMessage{sender: "Review",
+			text: fmt.Sprintf("couldn't run '%s': %s", res.Command, msg.err)})
+	case res.DryRun:
+		m.messages = append(m.messages, chatMessage{sender: "Review",
+			text: fmt.Sprintf("dry run, didn't run '%s'", res.Command)})
+	default:
+		out := []string{fmt.Sprintf("'%s' exited with %d", res.Command, res.ExitCode)}
+		if res.Stdout != "" {
+			out = append(out, strings.TrimRight(res.Stdout, "\n"))
+		}
+		if res.Stderr != "" {
+			out = append(out, m.errStyle.Render(strings.TrimRight(res.Stderr, "\n")))
+		}
+		m.messages = append(m.messages, chatMessage{sender: "Review", text: strings.Join(out, "\n")})
+		m.review.results = append(m.review.results, res)
+	}
+	return m.nextReview()
+}
+
+// write the file with the location, if it allows writing
+func (m *uimodel) applyFile(path, content string) string {
+	before, _ := m.location.Read(path)
+	entry := audit.Entry{
+		Type:   audit.FileEntry,
+		Path:   path,
+		Before: audit.Checksum(before),
+		After:  audit.Checksum(content),
+	}
+	defer func() { m.audit(entry) }()
+	if m.runner.DryRun {
+		entry.Status = audit.DryRun
+		return fmt.Sprintf("dry run, didn't write %s", path)
+	}
+	loc, ok := m.location.(file.Local)
+	if !ok {
+		entry.Status = audit.Failed
+		entry.Error = "location is read only"
+		return fmt.Sprintf("can't write %s, location is read only", path)
+	}
+	backupId, err := loc.Write(path, content)
+	if err != nil {
+		entry.Status = audit.Failed
+		entry.Error = err.Error()
+		return fmt.Sprintf("couldn't write %s: %s", path, err)
+	}
+	entry.Status = audit.Applied
+	entry.Backup = backupId
+	return fmt.Sprintf("wrote %s, revert with 'kowalski undo %s'", path, backupId)
+}
+
+// open the proposed content in the editor of the user
+func (m *uimodel) editAction(content string) tea.Cmd {
+	tmp, err := os.CreateTemp("", "kowalski-*")
+	if err != nil {
+		return func() tea.Msg { return editedMsg{err: err} }
+	}
+	tmp.WriteString(content)
+	tmp.Close()
+	// the editor may come with arguments like "code --wait"
+	editor := strings.Fields(os.Getenv("VISUAL"))
+	if len(editor) == 0 {
+		editor = strings.Fields(os.Getenv("EDITOR"))
+	}
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+	}
+	cmd := exec.Command(editor[0], append(editor[1:], tmp.Name())...)
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		defer os.Remove(tmp.Name())
+		if err != nil {
+			return editedMsg{err: err}
+		}
+		edited, err := os.ReadFile(tmp.Name())
+		return editedMsg{content: string(edited), err: err}
+	})
+}
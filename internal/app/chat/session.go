@@ -0,0 +1,45 @@
+package chat
+
+import (
+	"github.com/charmbracelet/log"
+	"github.com/openSUSE/kowalski/internal/pkg/actions"
+	"github.com/openSUSE/kowalski/internal/pkg/session"
+)
+
+// messages of a resumed session, the plans are parsed again from the answers
+func restoreMessages(sess *session.Session) []chatMessage {
+	messages := []chatMessage{}
+	for _, msg := range sess.Messages {
+		chatMsg := chatMessage{
+			sender:      msg.Sender,
+			text:        msg.Text,
+			sources:     msg.Sources,
+			interrupted: msg.Interrupted,
+		}
+		if msg.Sender == "Kowalski" {
+			chatMsg.plan = actions.Parse(msg.Text)
+		}
+		messages = append(messages, chatMsg)
+	}
+	return messages
+}
+
+// store the messages and the history, nothing is written for an empty chat
+func (m *uimodel) saveSession() {
+	if len(m.messages) == 0 {
+		return
+	}
+	m.session.Messages = m.session.Messages[:0]
+	for _, msg := range m.messages {
+		m.session.Messages = append(m.session.Messages, session.Message{
+			Sender:      msg.sender,
+			Text:        session.StripColor(msg.text),
+			Sources:     msg.sources,
+			Interrupted: msg.interrupted,
+		})
+	}
+	m.session.Turns = m.history
+	if err := m.session.Save(); err != nil {
+		log.Warnf("couldn't save session: %s", err)
+	}
+}
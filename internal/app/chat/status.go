@@ -0,0 +1,87 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/openSUSE/kowalski/internal/app/ollamaconnector"
+	"github.com/openSUSE/kowalski/internal/pkg/database"
+)
+
+// what the chat is waiting for
+type phase string
+
+const (
+	phaseIdle       phase = ""
+	phaseRetrieval  phase = "searching"
+	phaseGeneration phase = "generating"
+)
+
+// send with the last chunk of an answer
+type statsMsg struct {
+	stats       *ollamaconnector.Stats
+	contextSize int
+}
+
+/*
+Status bar below the messages, so that a slow modell can be told apart
+from an ollama which hangs.
+*/
+type statusBar struct {
+	spinner spinner.Model
+	phase   phase
+	// of the last answer
+	stats       *ollamaconnector.Stats
+	contextSize int
+	// of the last retrieval
+	sources  int
+	bestDist float32
+}
+
+func newStatusBar() statusBar {
+	return statusBar{
+		spinner: spinner.New(spinner.WithSpinner(spinner.Dot)),
+	}
+}
+
+// remember the number of sections and the best distance
+func (bar *statusBar) retrieved(sources []database.Source) {
+	bar.sources = len(sources)
+	for i, src := range sources {
+		if i == 0 || src.Dist < bar.bestDist {
+			bar.bestDist = src.Dist
+		}
+	}
+}
+
+func (m *uimodel) statusLine() string {
+	bar := &m.status
+	out := []string{m.model}
+	if bar.phase != phaseIdle {
+		out = append(out, bar.spinner.View()+" "+string(bar.phase))
+	}
+	if m.pull != nil {
+		out = append(out, m.pull.View())
+	}
+	if stats := bar.stats; stats != nil {
+		if stats.EvalDuration > 0 {
+			out = append(out, fmt.Sprintf("%.1f tok/s", float64(stats.EvalCount)/(float64(stats.EvalDuration)/1e9)))
+		}
+		if bar.contextSize > 0 {
+			out = append(out, fmt.Sprintf("prompt %d/%d tok", stats.PromptEvalCount, bar.contextSize))
+		} else {
+			out = append(out, fmt.Sprintf("prompt %d tok", stats.PromptEvalCount))
+		}
+	}
+	if bar.sources > 0 {
+		out = append(out, fmt.Sprintf("%d sections, best dist %.3f", bar.sources, bar.bestDist))
+	}
+	switch {
+	case m.opts.Server != "":
+		out = append(out, "server "+m.opts.Server)
+	case m.db != nil && m.db.IsReadOnly():
+		out = append(out, "db read-only")
+	}
+	return m.sourceStyle.Width(m.viewport.Width).MaxHeight(1).Render(strings.Join(out, " │ "))
+}
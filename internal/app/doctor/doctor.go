@@ -0,0 +1,283 @@
+/*
+Checks of everything kowalski needs, so that users can fix their setup
+and attach the report to a support ticket.
+*/
+package doctor
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/openSUSE/kowalski/internal/app/ollamaconnector"
+	"github.com/openSUSE/kowalski/internal/pkg/database"
+	"github.com/openSUSE/kowalski/internal/pkg/version"
+	"github.com/spf13/viper"
+	"go.etcd.io/bbolt"
+)
+
+type Status string
+
+const (
+	OK   Status = "ok"
+	Warn Status = "warn"
+	Fail Status = "fail"
+)
+
+type Check struct {
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// what the user can do about it
+	Fix string `json:"fix,omitempty"`
+}
+
+type Report struct {
+	Version   string  `json:"version"`
+	OllamaURL string  `json:"ollama_url"`
+	Model     string  `json:"model"`
+	Database  string  `json:"database"`
+	Checks    []Check `json:"checks"`
+}
+
+// a bolt file which is locked by a running kowalski would block forever
+const openTimeout = 2 * time.Second
+
+func (rep *Report) add(check Check) {
+	rep.Checks = append(rep.Checks, check)
+}
+
+// number of failed checks
+func (rep *Report) Failed() (failed int) {
+	for _, check := range rep.Checks {
+		if check.Status == Fail {
+			failed++
+		}
+	}
+	return
+}
+
+// run all checks, the ones depending on a failed check are skipped
+func Run(llm *ollamaconnector.Settings, dbPath string) Report {
+	rep := Report{
+		Version:   strings.TrimSpace(version.Version),
+		OllamaURL: llm.OllamaURL,
+		Model:     llm.LLM,
+		Database:  dbPath,
+	}
+	rep.add(checkConfig())
+	rep.add(checkOsRelease())
+	if rep.checkOllama(llm) {
+		rep.checkModel(llm)
+	}
+	if rep.checkDatabaseDir(dbPath) && rep.checkBoltFiles(dbPath) {
+		rep.checkCollections(llm, dbPath)
+	}
+	return rep
+}
+
+func checkConfig() Check {
+	check := Check{Name: "config file", Status: OK}
+	err := viper.ReadInConfig()
+	var notFound viper.ConfigFileNotFoundError
+	switch {
+	case errors.As(err, &notFound):
+		check.Detail = "no config file, using the defaults"
+	case err != nil:
+		check.Status = Fail
+		check.Detail = err.Error()
+		check.Fix = fmt.Sprintf("fix the yaml syntax of %s", viper.ConfigFileUsed())
+	default:
+		check.Detail = viper.ConfigFileUsed()
+	}
+	return check
+}
+
+func checkOsRelease() Check {
+	check := Check{Name: "/etc/os-release", Status: OK}
+	if _, err := os.Stat("/etc/os-release"); err != nil {
+		check.Status = Warn
+		check.Detail = err.Error()
+		check.Fix = "the prompt can't name the distribution, install the package providing /etc/os-release"
+		return check
+	}
+	info := database.GetSystemInfo()
+	check.Detail = fmt.Sprintf("%s %s", info.Name, info.Version)
+	return check
+}
+
+func (rep *Report) checkOllama(llm *ollamaconnector.Settings) bool {
+	check := Check{Name: "ollama reachable", Status: OK, Detail: llm.OllamaURL}
+	defer func() { rep.add(check) }()
+	if _, err := llm.FindModel(llm.LLM); err != nil {
+		check.Status = Fail
+		check.Detail = err.Error()
+		check.Fix = fmt.Sprintf("start ollama with 'systemctl start ollama' or point --url to it, currently %s", llm.OllamaURL)
+		return false
+	}
+	return true
+}
+
+func (rep *Report) checkModel(llm *ollamaconnector.Settings) {
+	found, err := llm.FindModel(llm.LLM)
+	check := Check{Name: "modell " + llm.LLM, Status: OK, Detail: "present"}
+	if err != nil || !found {
+		check.Status = Warn
+		check.Detail = "not present, it's pulled with the first question"
+		if llm.NoAutoPull {
+			check.Status = Fail
+			check.Detail = "not present and auto pull is disabled"
+		}
+		check.Fix = fmt.Sprintf("run 'kowalski model pull %s'", llm.LLM)
+		rep.add(check)
+		return
+	}
+	rep.add(check)
+	check = Check{Name: "context length", Status: OK}
+	if size := llm.GetContextSize(); size > 0 {
+		check.Detail = fmt.Sprintf("%d tokens", size)
+	} else {
+		check.Status = Fail
+		check.Detail = "ollama doesn't report the context length of " + llm.LLM
+		check.Fix = "choose another modell with --modell"
+	}
+	rep.add(check)
+}
+
+func (rep *Report) checkDatabaseDir(dbPath string) bool {
+	check := Check{Name: "database path", Status: OK, Detail: dbPath}
+	stat, err := os.Stat(dbPath)
+	if err == nil && !stat.IsDir() {
+		err = fmt.Errorf("%s is not a directory", dbPath)
+	}
+	if err == nil {
+		_, err = os.ReadDir(dbPath)
+	}
+	if err != nil {
+		check.Status = Fail
+		check.Detail = err.Error()
+		check.Fix = "add documentation with 'kowalski database add' or point --database to the knowledge database"
+		rep.add(check)
+		return false
+	}
+	rep.add(check)
+	check = Check{Name: "database writable", Status: OK}
+	if fh, err := os.CreateTemp(dbPath, ".doctor"); err == nil {
+		fh.Close()
+		os.Remove(fh.Name())
+	} else {
+		check.Status = Warn
+		check.Detail = "read-only, documents can't be added: " + err.Error()
+	}
+	rep.add(check)
+	return true
+}
+
+// open every bolt file read-only, returns false if there is none or one fails
+func (rep *Report) checkBoltFiles(dbPath string) bool {
+	files, err := fs.Glob(os.DirFS(dbPath), "*"+database.DBSuffix)
+	if err == nil && len(files) == 0 {
+		rep.add(Check{Name: "collections", Status: Warn, Detail: "the database is empty",
+			Fix: "add documentation with 'kowalski database add'"})
+		return false
+	}
+	ok := err == nil
+	for _, name := range files {
+		check := Check{Name: "bolt file " + name, Status: OK}
+		db, err := bbolt.Open(filepath.Join(dbPath, name), 0644, &bbolt.Options{ReadOnly: true, Timeout: openTimeout})
+		if err == nil {
+			db.Close()
+		} else {
+			ok = false
+			check.Status = Fail
+			check.Detail = err.Error()
+			if errors.Is(err, bbolt.ErrTimeout) {
+				check.Fix = "the file is locked, stop the running kowalski"
+			} else {
+				check.Fix = "the file is damaged, remove it and add the documentation again"
+			}
+		}
+		rep.add(check)
+	}
+	return ok
+}
+
+func (rep *Report) checkCollections(llm *ollamaconnector.Settings, dbPath string) {
+	kn, err := database.New(database.OptionWithFile(dbPath))
+	if err != nil {
+		rep.add(Check{Name: "database", Status: Fail, Detail: err.Error()})
+		return
+	}
+	defer kn.Close()
+	collections := kn.ListCollections()
+	sort.Strings(collections)
+	ok := true
+	for _, coll := range collections {
+		check := checkCollection(llm, kn, coll)
+		ok = ok && check.Status == OK
+		rep.add(check)
+	}
+	check := Check{Name: "embedding", Status: OK}
+	embedding, err := database.GetEmbedding(collections)
+	if err != nil {
+		check.Status = Fail
+		check.Detail = err.Error()
+		check.Fix = "all collections must use the same embedding, drop the ones with the other embedding"
+		rep.add(check)
+		return
+	}
+	check.Detail = embedding
+	rep.add(check)
+	if !ok {
+		return
+	}
+	check = Check{Name: "search index", Status: OK, Detail: fmt.Sprintf("%d collections", len(collections))}
+	if err := kn.CreateIndex(); err != nil {
+		check.Status = Fail
+		check.Detail = err.Error()
+	}
+	rep.add(check)
+}
+
+// the embedding of the collection must be present and match the stored vectors
+func checkCollection(llm *ollamaconnector.Settings, kn *database.Knowledge, coll string) Check {
+	check := Check{Name: "collection " + coll, Status: OK}
+	_, embedding, found := strings.Cut(coll, "@")
+	if !found {
+		check.Status = Fail
+		check.Detail = "the name doesn't contain the embedding after an @"
+		return check
+	}
+	if present, err := llm.FindModel(embedding); err != nil || !present {
+		check.Status = Fail
+		check.Detail = fmt.Sprintf("embedding %s isn't present", embedding)
+		check.Fix = fmt.Sprintf("run 'ollama pull %s'", embedding)
+		return check
+	}
+	dims, err := kn.VectorDimensions(coll)
+	if err != nil {
+		check.Status = Fail
+		check.Detail = err.Error()
+		return check
+	}
+	dim := llm.GetEmbeddingDimension(embedding)
+	var wrong, sections int
+	for vecDim, count := range dims {
+		sections += count
+		if vecDim != dim {
+			wrong += count
+		}
+	}
+	check.Detail = fmt.Sprintf("%d sections, %d dimensions", sections, dim)
+	if wrong > 0 {
+		check.Status = Fail
+		check.Detail = fmt.Sprintf("%d of %d sections don't have %d dimensions like %s", wrong, sections, dim, embedding)
+		check.Fix = "the embedding changed, drop the collection and add the documentation again"
+	}
+	return check
+}
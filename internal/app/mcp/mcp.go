@@ -0,0 +1,177 @@
+/*
+Serve the knowledge database over the Model Context Protocol, so that
+other agents can search the documentation. Only the stdio transport is
+implemented, which uses one json-rpc message per line.
+*/
+package mcp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	"github.com/openSUSE/kowalski/internal/pkg/database"
+	"github.com/openSUSE/kowalski/internal/pkg/file"
+	"github.com/openSUSE/kowalski/internal/pkg/version"
+)
+
+const protocolVersion = "2024-11-05"
+
+// json-rpc error codes
+const (
+	parseError     = -32700
+	invalidRequest = -32600
+	methodNotFound = -32601
+	invalidParams  = -32602
+	internalError  = -32603
+)
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (err *rpcError) Error() string {
+	return err.Message
+}
+
+type Server struct {
+	DB *database.Knowledge
+	// if set, the files of the system can be read with the system_file tool
+	Location file.Location
+	mutex    sync.Mutex
+	out      io.Writer
+}
+
+// handle the requests from in until it is closed
+func (srv *Server) Serve(in io.Reader, out io.Writer) error {
+	srv.out = out
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var req request
+		if err := json.Unmarshal(line, &req); err != nil {
+			srv.send(response{Id: json.RawMessage("null"), Error: &rpcError{parseError, err.Error()}})
+			continue
+		}
+		log.Debugf("mcp request: %s", req.Method)
+		result, err := srv.handle(req)
+		// notifications don't get an answer
+		if len(req.Id) == 0 {
+			continue
+		}
+		resp := response{Id: req.Id, Result: result}
+		if err != nil {
+			var rpcErr *rpcError
+			if !errors.As(err, &rpcErr) {
+				rpcErr = &rpcError{internalError, err.Error()}
+			}
+			resp.Result = nil
+			resp.Error = rpcErr
+		}
+		srv.send(resp)
+	}
+	return scanner.Err()
+}
+
+func (srv *Server) send(resp response) {
+	resp.JSONRPC = "2.0"
+	line, err := json.Marshal(resp)
+	if err != nil {
+		log.Warnf("couldn't marshal response: %s", err)
+		return
+	}
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	srv.out.Write(append(line, '\n'))
+}
+
+func (srv *Server) handle(req request) (any, error) {
+	if req.JSONRPC != "2.0" {
+		return nil, &rpcError{invalidRequest, "only json-rpc 2.0 is supported"}
+	}
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(req.Params, &params)
+		log.Debugf("client protocol version: %s", params.ProtocolVersion)
+		return map[string]any{
+			"protocolVersion": protocolVersion,
+			"capabilities": map[string]any{
+				"tools":     map[string]any{},
+				"resources": map[string]any{},
+			},
+			"serverInfo": map[string]any{
+				"name":    "kowalski",
+				"version": strings.TrimSpace(version.Version),
+			},
+		}, nil
+	case "notifications/initialized", "notifications/cancelled":
+		return nil, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		return map[string]any{"tools": srv.tools()}, nil
+	case "tools/call":
+		var params struct {
+			Name      string         `json:"name"`
+			Arguments map[string]any `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{invalidParams, err.Error()}
+		}
+		return srv.callTool(params.Name, params.Arguments)
+	case "resources/list":
+		return map[string]any{"resources": srv.resources()}, nil
+	case "resources/templates/list":
+		return map[string]any{"resourceTemplates": []map[string]any{{
+			"uriTemplate": documentURI + "{id}",
+			"name":        "document",
+			"description": "Document of the knowledge database with the given id",
+			"mimeType":    "text/plain",
+		}}}, nil
+	case "resources/read":
+		var params struct {
+			URI string `json:"uri"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{invalidParams, err.Error()}
+		}
+		text, err := srv.readResource(params.URI)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"contents": []map[string]any{{
+			"uri":      params.URI,
+			"mimeType": "text/plain",
+			"text":     text,
+		}}}, nil
+	default:
+		return nil, &rpcError{methodNotFound, fmt.Sprintf("method not found: %s", req.Method)}
+	}
+}
@@ -0,0 +1,121 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openSUSE/kowalski/internal/app/ollamaconnector"
+	"github.com/openSUSE/kowalski/internal/pkg/database"
+	"github.com/openSUSE/kowalski/internal/pkg/file"
+	"github.com/openSUSE/kowalski/internal/pkg/information"
+)
+
+// database with one document, the embeddings come from a fake ollama
+func testDB(t *testing.T) *database.Knowledge {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/tags", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"models":[{"name":"emb:latest"}]}`)
+	})
+	mux.HandleFunc("POST /api/show", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"model_info":{"general.architecture":"test","test.embedding_length":4,"test.context_length":8192}}`)
+	})
+	mux.HandleFunc("POST /api/embed", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"embeddings":[[1,0,0,0]]}`)
+	})
+	fake := httptest.NewServer(mux)
+	t.Cleanup(fake.Close)
+	ollamaconnector.Ollamasettings = ollamaconnector.Settings{OllamaURL: fake.URL}
+	db, err := database.New(database.OptionWithFile(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+	err = db.AddInformation("docs@emb", information.Information{
+		Hash:   "doc1",
+		Source: "install.xml",
+		Sections: []information.Section{{
+			Title: "Installing packages",
+			Lines: []information.Line{{Text: "Use zypper in to install a package."}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestServe(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "etc"), 0755)
+	os.WriteFile(filepath.Join(root, "etc", "hostname"), []byte("tumbleweed\n"), 0644)
+	srv := Server{DB: testDB(t), Location: file.Local{Chroot: root}}
+	call := func(id int, method, params string) string {
+		return fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":%q,"params":%s}`, id, method, params)
+	}
+	tests := []struct {
+		name  string
+		input string
+		// parts of the answer, no answer is expected if empty
+		want []string
+	}{
+		{"initialize", call(1, "initialize", `{"protocolVersion":"2025-03-26"}`),
+			[]string{`"id":1`, `"protocolVersion":"2024-11-05"`, `"name":"kowalski"`}},
+		{"notification", `{"jsonrpc":"2.0","method":"notifications/initialized"}`, nil},
+		{"ping", `{"jsonrpc":"2.0","id":"a","method":"ping"}`, []string{`"id":"a"`, `"result":{}`}},
+		{"tools", call(2, "tools/list", `{}`),
+			[]string{`"name":"search_docs"`, `"name":"get_document"`, `"name":"system_file"`}},
+		{"search", call(3, "tools/call", `{"name":"search_docs","arguments":{"query":"install vim"}}`),
+			[]string{`Document id: doc1`, `Source: install.xml`, `Use zypper in`}},
+		{"search without query", call(4, "tools/call", `{"name":"search_docs","arguments":{}}`),
+			[]string{`missing argument: query`, `"isError":true`}},
+		{"document", call(5, "tools/call", `{"name":"get_document","arguments":{"id":"doc1"}}`),
+			[]string{`Source: install.xml`, `Installing packages`}},
+		{"system file", call(6, "tools/call", `{"name":"system_file","arguments":{"path":"/etc/hostname"}}`),
+			[]string{`tumbleweed`}},
+		{"unknown tool", call(7, "tools/call", `{"name":"rm","arguments":{}}`),
+			[]string{`unknown tool: rm`, `"isError":true`}},
+		{"resources", call(8, "resources/list", `{}`),
+			[]string{`"uri":"kowalski://collection/docs@emb"`}},
+		{"read collection", call(9, "resources/read", `{"uri":"kowalski://collection/docs@emb"}`),
+			[]string{`"text":"doc1 install.xml"`}},
+		{"read document", call(10, "resources/read", `{"uri":"kowalski://document/doc1"}`),
+			[]string{`"uri":"kowalski://document/doc1"`, `Use zypper in`}},
+		{"read unknown", call(11, "resources/read", `{"uri":"file:///etc/passwd"}`),
+			[]string{`"code":-32602`, `unknown resource`}},
+		{"unknown method", call(12, "sampling/createMessage", `{}`),
+			[]string{`"code":-32601`}},
+		{"wrong version", `{"jsonrpc":"1.0","id":13,"method":"ping"}`,
+			[]string{`"code":-32600`}},
+		{"broken json", `{"jsonrpc":`, []string{`"id":null`, `"code":-32700`}},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		if err := srv.Serve(strings.NewReader(test.input+"\n"), &out); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		answer := strings.TrimSpace(out.String())
+		if len(test.want) == 0 {
+			if answer != "" {
+				t.Errorf("%s: unexpected answer %s", test.name, answer)
+			}
+			continue
+		}
+		var resp map[string]any
+		if err := json.Unmarshal([]byte(answer), &resp); err != nil || resp["jsonrpc"] != "2.0" {
+			t.Errorf("%s: invalid answer %s: %v", test.name, answer, err)
+			continue
+		}
+		for _, want := range test.want {
+			if !strings.Contains(answer, want) {
+				t.Errorf("%s: %s doesn't contain %s", test.name, answer, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package mcp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openSUSE/kowalski/internal/pkg/templates"
+)
+
+const (
+	collectionURI = "kowalski://collection/"
+	documentURI   = "kowalski://document/"
+)
+
+// number of sections search_docs returns if no limit is given
+const defaultLimit = 5
+
+func (srv *Server) tools() (tools []map[string]any) {
+	tools = append(tools,
+		map[string]any{
+			"name":        "search_docs",
+			"description": "Search the distribution documentation for sections related to the query",
+			"inputSchema": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"query": map[string]any{"type": "string", "description": "what to search for"},
+					"collections": map[string]any{"type": "array", "items": map[string]any{"type": "string"},
+						"description": "collections to search in, all if empty"},
+					"limit": map[string]any{"type": "integer", "description": "maximal number of sections"},
+				},
+				"required": []string{"query"},
+			},
+		},
+		map[string]any{
+			"name":        "get_document",
+			"description": "Get the whole document with the id returned by search_docs",
+			"inputSchema": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"id": map[string]any{"type": "string", "description": "id of the document"},
+				},
+				"required": []string{"id"},
+			},
+		})
+	if srv.Location != nil {
+		tools = append(tools, map[string]any{
+			"name":        "system_file",
+			"description": "Read a file of the system kowalski is running on",
+			"inputSchema": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"path": map[string]any{"type": "string", "description": "absolute path of the file"},
+				},
+				"required": []string{"path"},
+			},
+		})
+	}
+	return
+}
+
+// errors of the tools are reported to the modell and not as protocol errors
+func (srv *Server) callTool(name string, args map[string]any) (any, error) {
+	text, err := srv.runTool(name, args)
+	if err != nil {
+		return map[string]any{
+			"content": []map[string]any{{"type": "text", "text": err.Error()}},
+			"isError": true,
+		}, nil
+	}
+	return map[string]any{
+		"content": []map[string]any{{"type": "text", "text": text}},
+	}, nil
+}
+
+func (srv *Server) runTool(name string, args map[string]any) (string, error) {
+	switch name {
+	case "search_docs":
+		query, _ := args["query"].(string)
+		if query == "" {
+			return "", fmt.Errorf("missing argument: query")
+		}
+		var collections []string
+		if colls, ok := args["collections"].([]any); ok {
+			for _, coll := range colls {
+				if str, ok := coll.(string); ok {
+					collections = append(collections, str)
+				}
+			}
+		}
+		if len(collections) == 0 {
+			collections = srv.DB.ListCollections()
+		}
+		limit := int64(defaultLimit)
+		if lim, ok := args["limit"].(float64); ok && lim > 0 {
+			limit = int64(lim)
+		}
+		infos, err := srv.DB.GetInfos(query, collections, limit)
+		if err != nil {
+			return "", err
+		}
+		var out []string
+		for _, info := range infos {
+			str, err := info.Section.Render()
+			if err != nil {
+				return "", err
+			}
+			out = append(out, fmt.Sprintf("Document id: %s\nSource: %s\nDistance: %.3f%s",
+				info.Hash, info.Source, info.Dist, str))
+		}
+		if len(out) == 0 {
+			return "nothing found", nil
+		}
+		return strings.Join(out, "\n\n"), nil
+	case "get_document":
+		id, _ := args["id"].(string)
+		if id == "" {
+			return "", fmt.Errorf("missing argument: id")
+		}
+		return srv.document(id)
+	case "system_file":
+		if srv.Location == nil {
+			return "", fmt.Errorf("unknown tool: %s", name)
+		}
+		path, _ := args["path"].(string)
+		if path == "" {
+			return "", fmt.Errorf("missing argument: path")
+		}
+		return srv.Location.Read(path)
+	default:
+		return "", fmt.Errorf("unknown tool: %s", name)
+	}
+}
+
+// all sections of the document
+func (srv *Server) document(id string) (string, error) {
+	info, err := srv.DB.Get(id)
+	if err != nil {
+		return "", err
+	}
+	out := []string{"Source: " + info.Source}
+	for _, sec := range info.Sections {
+		if sec.IsAlias {
+			continue
+		}
+		str, err := sec.Render(templates.Get(templates.RenderInfoName))
+		if err != nil {
+			return "", err
+		}
+		out = append(out, str)
+	}
+	return strings.Join(out, "\n"), nil
+}
+
+func (srv *Server) resources() (resources []map[string]any) {
+	for _, coll := range srv.DB.ListCollections() {
+		resources = append(resources, map[string]any{
+			"uri":         collectionURI + coll,
+			"name":        coll,
+			"description": "List of the documents in collection " + coll,
+			"mimeType":    "text/plain",
+		})
+	}
+	return
+}
+
+func (srv *Server) readResource(uri string) (string, error) {
+	switch {
+	case strings.HasPrefix(uri, collectionURI):
+		docs, err := srv.DB.List(strings.TrimPrefix(uri, collectionURI))
+		if err != nil {
+			return "", &rpcError{invalidParams, err.Error()}
+		}
+		var out []string
+		for _, doc := range docs {
+			out = append(out, fmt.Sprintf("%s %s", doc.Id, doc.Source))
+		}
+		return strings.Join(out, "\n"), nil
+	case strings.HasPrefix(uri, documentURI):
+		text, err := srv.document(strings.TrimPrefix(uri, documentURI))
+		if err != nil {
+			return "", &rpcError{invalidParams, err.Error()}
+		}
+		return text, nil
+	default:
+		return "", &rpcError{invalidParams, "unknown resource: " + uri}
+	}
+}
@@ -0,0 +1,186 @@
+package ollamaconnector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// modell as listed by ollama
+type ModelEntry struct {
+	Name       string    `json:"name"`
+	Size       int64     `json:"size"`
+	Digest     string    `json:"digest"`
+	ModifiedAt time.Time `json:"modified_at"`
+	Details    struct {
+		Family            string `json:"family"`
+		ParameterSize     string `json:"parameter_size"`
+		QuantizationLevel string `json:"quantization_level"`
+	} `json:"details"`
+}
+
+// state of a pull, total and completed are the bytes of the layer with digest
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// fraction of the current layer which was downloaded
+func (prog PullProgress) Fraction() float64 {
+	if prog.Total <= 0 {
+		return 0
+	}
+	return float64(prog.Completed) / float64(prog.Total)
+}
+
+func (settings *Settings) url(path string) string {
+	return strings.TrimSuffix(settings.OllamaURL, "/") + path
+}
+
+// list the modells present on the ollama instance
+func (settings *Settings) ListModels() ([]ModelEntry, error) {
+	httpResp, err := http.Get(settings.url("/api/tags"))
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("couldn't list models of ollama: %s", httpResp.Status)
+	}
+	var modelResp struct {
+		Models []ModelEntry `json:"models"`
+	}
+	if err = json.NewDecoder(httpResp.Body).Decode(&modelResp); err != nil {
+		return nil, errors.New("couldn't parse models from server")
+	}
+	return modelResp.Models, nil
+}
+
+// check for model on the ollam instance, a name without tag means latest
+func (settings *Settings) FindModel(name string) (found bool, err error) {
+	models, err := settings.ListModels()
+	if err != nil {
+		return false, err
+	}
+	for _, it := range models {
+		if it.Name == name || it.Name == name+":latest" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+/*
+Pull the modell and send the progress to the channel, which is closed at
+the end. An error reported by ollama in the stream is returned.
+*/
+func (settings *Settings) Pull(ctx context.Context, name string, progress chan PullProgress) (err error) {
+	defer close(progress)
+	js, err := json.Marshal(struct {
+		Model  string `json:"model"`
+		Stream bool   `json:"stream"`
+	}{
+		Model:  name,
+		Stream: true,
+	})
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, settings.url("/api/pull"), bytes.NewReader(js))
+	if err != nil {
+		return err
+	}
+	httpResp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("couldn't pull %s: %w", name, err)
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("couldn't pull %s: %s", name, httpResp.Status)
+	}
+	dec := json.NewDecoder(httpResp.Body)
+	for {
+		var prog PullProgress
+		if err = dec.Decode(&prog); err != nil {
+			break
+		}
+		if prog.Error != "" {
+			return fmt.Errorf("couldn't pull %s: %s", name, prog.Error)
+		}
+		progress <- prog
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+	return
+}
+
+// delete the modell from the ollama instance
+func (settings *Settings) DeleteModel(name string) error {
+	js, err := json.Marshal(struct {
+		Model string `json:"model"`
+	}{
+		Model: name,
+	})
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequest(http.MethodDelete, settings.url("/api/delete"), bytes.NewReader(js))
+	if err != nil {
+		return err
+	}
+	httpResp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	switch httpResp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("modell %s isn't present", name)
+	default:
+		return fmt.Errorf("couldn't delete %s: %s", name, httpResp.Status)
+	}
+}
+
+/*
+Pull the model if not present, the progress is logged. Returns an error if
+the modell is missing and NoAutoPull is set, the pull stops with ctx.
+*/
+func (settings *Settings) PullModel(ctx context.Context, name string) (err error) {
+	found, err := settings.FindModel(name)
+	if err != nil {
+		return err
+	}
+	if found {
+		return nil
+	}
+	if settings.NoAutoPull {
+		return fmt.Errorf("modell %s isn't present, pull it with 'kowalski model pull %s'", name, name)
+	}
+	log.Infof("pulling modell %s", name)
+	progress := make(chan PullProgress)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- settings.Pull(ctx, name, progress)
+	}()
+	for prog := range progress {
+		log.Debugf("pulling %s: %s %d/%d", name, prog.Status, prog.Completed, prog.Total)
+	}
+	return <-errCh
+}
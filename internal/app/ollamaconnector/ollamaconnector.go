@@ -2,34 +2,54 @@ package ollamaconnector
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/charmbracelet/log"
 )
 
+// answers prompts, the local ollama or a remote kowalski server
+type Generator interface {
+	SendTask(ctx context.Context, msg string) (*TaskResponse, error)
+	SendTaskStream(ctx context.Context, msg string, resp chan *TaskResponse) error
+	GetContextSize() int
+}
+
 // configuration of LLM modell and connection to ollama
 // embedding is inheritly coupled the stored information
 type Settings struct {
-	LLM         string
-	OllamaURL   string
+	LLM       string
+	OllamaURL string
+	// missing modells aren't pulled, but reported as error
+	NoAutoPull bool
+	// generation options by modell name
+	Profiles map[string]Profile
+	// options from the command line
+	Overrides   map[string]any
 	contextSize int
 	info        ModelInfo
 }
 
 var Ollamasettings Settings
 
+// the context size is looked up lazily, also by concurrent requests of the server
+var contextMu sync.Mutex
+
 type TaskRequest struct {
-	Model   string         `json:"model"`
-	Prompt  string         `json:"prompt"`
-	Format  string         `json:"format"`
-	Options map[string]any `json:"options"`
-	Stream  bool           `json:"stream"`
-	System  string         `json:"system"`
+	Model     string         `json:"model"`
+	Prompt    string         `json:"prompt"`
+	Format    string         `json:"format"`
+	Options   map[string]any `json:"options"`
+	Stream    bool           `json:"stream"`
+	System    string         `json:"system,omitempty"`
+	KeepAlive string         `json:"keep_alive,omitempty"`
 }
 
 type GenerateReq struct {
@@ -40,9 +60,52 @@ type GenerateReq struct {
 }
 
 type Message struct {
-	Role       string `json:"role"`
-	Content    string `json:"content"`
-	Tool_Calls []any  `json:"tool_calls"`
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	Tool_Calls []ToolCall `json:"tool_calls,omitempty"`
+	// name of the tool for messages with the role tool
+	ToolName string `json:"tool_name,omitempty"`
+}
+
+// tool the modell may call, the parameters are a json schema
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+type ToolFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Parameters  map[string]any `json:"parameters"`
+}
+
+type ToolCall struct {
+	Function struct {
+		Name      string         `json:"name"`
+		Arguments map[string]any `json:"arguments"`
+	} `json:"function"`
+}
+
+type ChatRequest struct {
+	Model     string         `json:"model"`
+	Messages  []Message      `json:"messages"`
+	Tools     []Tool         `json:"tools,omitempty"`
+	Options   map[string]any `json:"options"`
+	Stream    bool           `json:"stream"`
+	KeepAlive string         `json:"keep_alive,omitempty"`
+}
+
+type ChatResponse struct {
+	Model              string    `json:"model"`
+	CreatedAt          time.Time `json:"created_at"`
+	Message            Message   `json:"message"`
+	Done               bool      `json:"done"`
+	TotalDuration      int64     `json:"total_duration"`
+	LoadDuration       int       `json:"load_duration"`
+	PromptEvalCount    int       `json:"prompt_eval_count"`
+	PromptEvalDuration int       `json:"prompt_eval_duration"`
+	EvalCount          int       `json:"eval_count"`
+	EvalDuration       int64     `json:"eval_duration"`
 }
 
 type EmbeddingRequest struct {
@@ -71,6 +134,30 @@ type TaskResponse struct {
 	EvalDuration       int64     `json:"eval_duration"`
 }
 
+// generation statistics of an answer, the durations are in nanoseconds
+type Stats struct {
+	Model              string `json:"model"`
+	TotalDuration      int64  `json:"total_duration"`
+	LoadDuration       int    `json:"load_duration"`
+	PromptEvalCount    int    `json:"prompt_eval_count"`
+	PromptEvalDuration int    `json:"prompt_eval_duration"`
+	EvalCount          int    `json:"eval_count"`
+	EvalDuration       int64  `json:"eval_duration"`
+}
+
+// statistics of the last response of a stream
+func (resp *TaskResponse) Stats() *Stats {
+	return &Stats{
+		Model:              resp.Model,
+		TotalDuration:      resp.TotalDuration,
+		LoadDuration:       resp.LoadDuration,
+		PromptEvalCount:    resp.PromptEvalCount,
+		PromptEvalDuration: resp.PromptEvalDuration,
+		EvalCount:          resp.EvalCount,
+		EvalDuration:       resp.EvalDuration,
+	}
+}
+
 type ModelInfo struct {
 	isSet         bool
 	License       string         `json:"license,omitempty"`
@@ -83,13 +170,18 @@ type ModelInfo struct {
 	ModifiedAt    time.Time      `json:"modified_at,omitempty"`
 }
 
-func (settings Settings) SendTask(msg string) (resp *TaskResponse, err error) {
-	settings.PullModel(settings.LLM)
+func (settings Settings) SendTask(ctx context.Context, msg string) (resp *TaskResponse, err error) {
+	if err := settings.PullModel(ctx, settings.LLM); err != nil {
+		return nil, err
+	}
+	gen := settings.Generation()
 	req := TaskRequest{
-		Prompt:  msg,
-		Model:   settings.LLM,
-		Options: map[string]any{"temperature": 0},
-		Stream:  false,
+		Prompt:    msg,
+		Model:     settings.LLM,
+		Options:   gen.Options,
+		Stream:    false,
+		System:    gen.System,
+		KeepAlive: gen.KeepAlive,
 	}
 	URL := strings.TrimSuffix(settings.OllamaURL, "/") + "/api/generate"
 	js, err := json.Marshal(req)
@@ -97,7 +189,7 @@ func (settings Settings) SendTask(msg string) (resp *TaskResponse, err error) {
 		return nil, fmt.Errorf("couldn't marshal message: %s", err)
 	}
 	client := http.Client{}
-	httpReq, err := http.NewRequest(http.MethodPost, URL, bytes.NewReader(js))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, URL, bytes.NewReader(js))
 	if err != nil {
 		return nil, fmt.Errorf("URL: %s Model: %s Error: %v", URL, settings.LLM, err)
 	}
@@ -106,19 +198,32 @@ func (settings Settings) SendTask(msg string) (resp *TaskResponse, err error) {
 		return nil, fmt.Errorf("URL: %s Model: %s Error: %v", URL, settings.LLM, err)
 	}
 	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("URL: %s Model: %s Status: %s", URL, settings.LLM, httpResp.Status)
+	}
 	ollamaResp := TaskResponse{}
 	err = json.NewDecoder(httpResp.Body).Decode(&ollamaResp)
 	return &ollamaResp, err
 }
 
-func (settings Settings) SendTaskStream(msg string, resp chan *TaskResponse) (err error) {
-	settings.PullModel(settings.LLM)
+/*
+Stream the answer to resp, which is closed at the end. If the context is
+canceled the request is aborted and the context error is returned.
+*/
+func (settings Settings) SendTaskStream(ctx context.Context, msg string, resp chan *TaskResponse) (err error) {
+	// close on errors as well, so that the reader doesn't wait forever
+	defer close(resp)
+	if err := settings.PullModel(ctx, settings.LLM); err != nil {
+		return err
+	}
+	gen := settings.Generation()
 	req := TaskRequest{
-		Prompt: msg,
-		// System: templates.SystemPrompt,
-		Model:   settings.LLM,
-		Options: map[string]any{"temperature": 0},
-		Stream:  true,
+		Prompt:    msg,
+		Model:     settings.LLM,
+		Options:   gen.Options,
+		Stream:    true,
+		System:    gen.System,
+		KeepAlive: gen.KeepAlive,
 	}
 	URL := strings.TrimSuffix(settings.OllamaURL, "/") + "/api/generate"
 	js, err := json.Marshal(req)
@@ -126,7 +231,7 @@ func (settings Settings) SendTaskStream(msg string, resp chan *TaskResponse) (er
 		return fmt.Errorf("couldn't marshal message: %s", err)
 	}
 	client := http.Client{}
-	httpReq, err := http.NewRequest(http.MethodPost, URL, bytes.NewReader(js))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, URL, bytes.NewReader(js))
 	if err != nil {
 		return fmt.Errorf("Error when creating Request: URL: %s Model: %s Error: %v", URL, settings.LLM, err)
 	}
@@ -134,6 +239,10 @@ func (settings Settings) SendTaskStream(msg string, resp chan *TaskResponse) (er
 	if err != nil {
 		return fmt.Errorf("Error during request retrival: URL: %s Model: %s Error: %v", URL, settings.LLM, err)
 	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("URL: %s Model: %s Status: %s", URL, settings.LLM, httpResp.Status)
+	}
 	dec := json.NewDecoder(httpResp.Body)
 	for {
 		ollamaResp := TaskResponse{}
@@ -143,12 +252,63 @@ func (settings Settings) SendTaskStream(msg string, resp chan *TaskResponse) (er
 		}
 		resp <- &ollamaResp
 	}
-	close(resp)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
 	return
 }
 
+/*
+Send the messages to the chat endpoint of ollama, the modell may answer
+with calls of the given tools instead of content.
+*/
+func (settings Settings) SendChat(ctx context.Context, messages []Message, tools []Tool) (resp *ChatResponse, err error) {
+	if err := settings.PullModel(ctx, settings.LLM); err != nil {
+		return nil, err
+	}
+	gen := settings.Generation()
+	// the system prompt of the profile is only added if the caller has none
+	if gen.System != "" && (len(messages) == 0 || messages[0].Role != "system") {
+		messages = append([]Message{{Role: "system", Content: gen.System}}, messages...)
+	}
+	req := ChatRequest{
+		Model:     settings.LLM,
+		Messages:  messages,
+		Tools:     tools,
+		Options:   gen.Options,
+		Stream:    false,
+		KeepAlive: gen.KeepAlive,
+	}
+	URL := strings.TrimSuffix(settings.OllamaURL, "/") + "/api/chat"
+	js, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't marshal message: %s", err)
+	}
+	client := http.Client{}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, URL, bytes.NewReader(js))
+	if err != nil {
+		return nil, fmt.Errorf("URL: %s Model: %s Error: %v", URL, settings.LLM, err)
+	}
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("URL: %s Model: %s Error: %v", URL, settings.LLM, err)
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("URL: %s Model: %s Status: %s", URL, settings.LLM, httpResp.Status)
+	}
+	chatResp := ChatResponse{}
+	err = json.NewDecoder(httpResp.Body).Decode(&chatResp)
+	return &chatResp, err
+}
+
 func (settings Settings) GetEmbeddings(emb []string, embedding string) (*EmbeddingResponse, error) {
-	settings.PullModel(embedding)
+	if err := settings.PullModel(context.Background(), embedding); err != nil {
+		return nil, err
+	}
 	URL := strings.TrimSuffix(settings.OllamaURL, "/") + "/api/embed"
 	req := EmbeddingRequest{
 		Input: emb,
@@ -209,24 +369,50 @@ func (settings *Settings) GetEmbeddingSize(embedding string) (size uint, err err
 	}
 }
 
+// switch to another modell, the cached information of the old one is dropped
+func (settings *Settings) SetModel(name string) {
+	settings.LLM = name
+	contextMu.Lock()
+	settings.contextSize = 0
+	contextMu.Unlock()
+	settings.info = ModelInfo{}
+}
+
 /*
 Get the context size
 */
 func (settings *Settings) GetContextSize() int {
-	if settings.contextSize != 0 {
-		return settings.contextSize
+	contextMu.Lock()
+	size := settings.contextSize
+	contextMu.Unlock()
+	if size != 0 {
+		return size
+	}
+	// the lock isn't held while ollama is asked, which may pull the modell
+	size = settings.lookupContextSize()
+	if size > 0 {
+		contextMu.Lock()
+		settings.contextSize = size
+		contextMu.Unlock()
+	}
+	return size
+}
+
+func (settings *Settings) lookupContextSize() int {
+	// ollama cuts the prompt to num_ctx
+	if numCtx := settings.Generation().NumCtx(); numCtx > 0 {
+		return numCtx
 	}
 	info, err := settings.GetModelInfo(settings.LLM)
 	if err != nil {
 		log.Warnf("couldn't get context size: %s", err)
 		return 0
 	}
-	if modelArch, ok := info.ModelInfo["general.architecture"].(string); ok {
-		settings.contextSize = int(info.ModelInfo[modelArch+".context_length"].(float64))
-		return settings.contextSize
-	} else {
-		log.Warnf("couldn't get context size for %s", modelArch)
+	modelArch, _ := info.ModelInfo["general.architecture"].(string)
+	if size, ok := info.ModelInfo[modelArch+".context_length"].(float64); ok {
+		return int(size)
 	}
+	log.Warnf("couldn't get context size for %s", modelArch)
 	return 0
 }
 
@@ -234,7 +420,9 @@ func (settings *Settings) GetContextSize() int {
 Get the basic information of the model via the REST API from ollma
 */
 func (settings Settings) GetModelInfo(name string) (*ModelInfo, error) {
-	settings.PullModel(name)
+	if err := settings.PullModel(context.Background(), name); err != nil {
+		return nil, err
+	}
 	if settings.info.isSet {
 		return &settings.info, nil
 	}
@@ -267,66 +455,3 @@ func (settings Settings) GetModelInfo(name string) (*ModelInfo, error) {
 	settings.info.isSet = true
 	return &settings.info, nil
 }
-
-// check for model on the ollam instance
-func (settings *Settings) FindModel(name string) (found bool, err error) {
-	URL := strings.TrimSuffix(settings.OllamaURL, "/") + "/api/tags"
-	modelResp := struct {
-		Models []map[string]any `json:"Models"`
-	}{}
-	httpGet, err := http.Get(URL)
-	if err != nil {
-		return false, err
-	}
-	if httpGet.StatusCode != http.StatusOK {
-		return false, errors.New("couldn't list models of ollana")
-	}
-	err = json.NewDecoder(httpGet.Body).Decode(&modelResp)
-	if err != nil {
-		return false, errors.New("couldn't parse models from server")
-	}
-	for _, it := range modelResp.Models {
-		ok := it["name"] == name
-		if ok {
-			return ok, nil
-		}
-	}
-	return false, nil
-}
-
-// pull model if not present
-func (settings *Settings) PullModel(name string) (err error) {
-	found, err := settings.FindModel(name)
-	if err != nil {
-		return err
-	}
-	if found {
-		return nil
-	}
-	URL := strings.TrimSuffix(settings.OllamaURL, "/") + "/api/pull"
-	var req = struct {
-		Model  string `json:"model"`
-		Stream bool   `json:"stream,omitempty"`
-	}{
-		Model:  name,
-		Stream: false,
-	}
-	js, _ := json.Marshal(req)
-	client := http.Client{}
-	httpReq, err := http.NewRequest(http.MethodPost, URL, bytes.NewReader(js))
-	httpResp, err := client.Do(httpReq)
-	if httpResp.StatusCode != http.StatusOK {
-		return errors.New("couldn't pull modell")
-	}
-	/*
-		reader := bufio.NewReader(httpResp.Body)
-			for {
-				line, err := reader.ReadBytes('\n')
-				if err != nil {
-					break
-				}
-				log.Debugf("pulling: %s", string(line))
-			}
-	*/
-	return nil
-}
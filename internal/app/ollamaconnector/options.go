@@ -0,0 +1,136 @@
+package ollamaconnector
+
+import (
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+)
+
+/*
+Generation options of a modell from the models section of the config, e.g.
+
+	models:
+	  gemma3:4b:
+	    temperature: 0.2
+	    num_ctx: 8192
+	    system: You are a helpful assistant.
+
+The keys system and keep_alive are sent with the request, all others are
+passed as options to ollama.
+*/
+type Profile map[string]any
+
+// options, system prompt and keep alive which are sent to ollama
+type Generation struct {
+	Model     string         `json:"model" yaml:"model"`
+	Options   map[string]any `json:"options" yaml:"options"`
+	System    string         `json:"system,omitempty" yaml:"system,omitempty"`
+	KeepAlive string         `json:"keep_alive,omitempty" yaml:"keep_alive,omitempty"`
+}
+
+// used if neither the profile nor the command line set them
+var defaultOptions = map[string]any{"temperature": 0}
+
+/*
+Set the profiles from the models section of the config. Viper returns
+the section as nested maps.
+*/
+func (settings *Settings) SetProfiles(models any) error {
+	if models == nil {
+		return nil
+	}
+	section, ok := models.(map[string]any)
+	if !ok {
+		return fmt.Errorf("models in the config must be a map of modell names, not %T", models)
+	}
+	settings.Profiles = make(map[string]Profile)
+	for name, val := range section {
+		profile, ok := val.(map[string]any)
+		if !ok {
+			return fmt.Errorf("profile of modell %s must be a map, not %T", name, val)
+		}
+		settings.Profiles[name] = profile
+	}
+	return nil
+}
+
+/*
+Set the options given as key=value on the command line, they override the
+profile. Numbers and booleans are converted, stop may be given several
+times.
+*/
+func (settings *Settings) SetOverrides(opts []string) error {
+	settings.Overrides = make(map[string]any)
+	for _, opt := range opts {
+		key, val, found := strings.Cut(opt, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return fmt.Errorf("option must be given as key=value: %s", opt)
+		}
+		if key == "stop" {
+			stops, _ := settings.Overrides[key].([]string)
+			settings.Overrides[key] = append(stops, val)
+			continue
+		}
+		settings.Overrides[key] = parseValue(val)
+	}
+	return nil
+}
+
+func parseValue(val string) any {
+	if i, err := strconv.Atoi(val); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(val, 64); err == nil {
+		return f
+	}
+	if b, err := strconv.ParseBool(val); err == nil {
+		return b
+	}
+	return val
+}
+
+// profile of the modell, viper lowercases the keys of the config
+func (settings *Settings) profile(name string) Profile {
+	for _, candidate := range []string{name, strings.TrimSuffix(name, ":latest")} {
+		for key, profile := range settings.Profiles {
+			if strings.EqualFold(key, candidate) {
+				return profile
+			}
+		}
+	}
+	return nil
+}
+
+// the defaults, overridden by the profile of the modell and the command line
+func (settings *Settings) Generation() Generation {
+	gen := Generation{
+		Model:   settings.LLM,
+		Options: maps.Clone(defaultOptions),
+	}
+	for _, opts := range []map[string]any{settings.profile(settings.LLM), settings.Overrides} {
+		for key, val := range opts {
+			switch key {
+			case "system":
+				gen.System = fmt.Sprint(val)
+			case "keep_alive":
+				gen.KeepAlive = fmt.Sprint(val)
+			default:
+				gen.Options[key] = val
+			}
+		}
+	}
+	return gen
+}
+
+// context size set with num_ctx, 0 if not set
+func (gen Generation) NumCtx() int {
+	switch val := gen.Options["num_ctx"].(type) {
+	case int:
+		return val
+	case float64:
+		return int(val)
+	}
+	return 0
+}
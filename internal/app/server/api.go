@@ -0,0 +1,84 @@
+package server
+
+import (
+	"github.com/openSUSE/kowalski/internal/app/ollamaconnector"
+	"github.com/openSUSE/kowalski/internal/pkg/database"
+	"github.com/openSUSE/kowalski/internal/pkg/information"
+)
+
+// requests and responses of the REST API, see schema.json
+
+type AskRequest struct {
+	Question    string          `json:"question"`
+	Collections []string        `json:"collections,omitempty"`
+	History     []database.Turn `json:"history,omitempty"`
+}
+
+// types of the events streamed as answer of an ask request
+const (
+	SourcesEvent = "sources"
+	ChunkEvent   = "chunk"
+	DoneEvent    = "done"
+	ErrorEvent   = "error"
+)
+
+// generate the answer for a prompt which was created by the client
+type GenerateRequest struct {
+	Prompt string `json:"prompt"`
+}
+
+type AskEvent struct {
+	Type     string                 `json:"type"`
+	Sources  []database.Source      `json:"sources,omitempty"`
+	Response string                 `json:"response,omitempty"`
+	Stats    *ollamaconnector.Stats `json:"stats,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+}
+
+type RetrieveRequest struct {
+	Query       string   `json:"query"`
+	Collections []string `json:"collections,omitempty"`
+	Limit       int64    `json:"limit,omitempty"`
+}
+
+// retrieved section, without its embedding
+type Section struct {
+	database.Source
+	Section information.Section `json:"section"`
+}
+
+type RetrieveResponse struct {
+	Sections []Section `json:"sections"`
+}
+
+type CollectionsResponse struct {
+	Collections []string `json:"collections"`
+}
+
+type DocumentsResponse struct {
+	Documents []database.DocumentInfo `json:"documents"`
+}
+
+type AddRequest struct {
+	// name of the document, e.g. the path of the file it was read from
+	Source string `json:"source"`
+	// xml for docbook or yaml for curated information
+	Format  string `json:"format"`
+	Content string `json:"content"`
+}
+
+type AddResponse struct {
+	Id string `json:"id"`
+}
+
+type HealthResponse struct {
+	Status      string `json:"status"`
+	Version     string `json:"version"`
+	Model       string `json:"model"`
+	ContextSize int    `json:"context_size"`
+	ReadOnly    bool   `json:"read_only"`
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
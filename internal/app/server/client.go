@@ -0,0 +1,237 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	"github.com/openSUSE/kowalski/internal/app/ollamaconnector"
+	"github.com/openSUSE/kowalski/internal/pkg/information"
+)
+
+/*
+Client of a remote kowalski server. It retrieves the documents and
+generates the answers on the server, so it can be used as retriever and
+generator while the prompt is created locally.
+*/
+type Client struct {
+	URL string
+	// Ping runs in the background while the answers use the health
+	mu     sync.Mutex
+	health *HealthResponse
+}
+
+func NewClient(serverURL string) *Client {
+	return &Client{URL: strings.TrimSuffix(serverURL, "/")}
+}
+
+// send the request, the response is decoded into resp if it's not nil
+func (cl *Client) do(method, path string, req, resp any) error {
+	httpResp, err := cl.send(context.Background(), method, path, req, "")
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	if resp == nil {
+		return nil
+	}
+	return json.NewDecoder(httpResp.Body).Decode(resp)
+}
+
+// send the request and check the status, the body must be closed by the caller
+func (cl *Client) send(ctx context.Context, method, path string, req any, accept string) (*http.Response, error) {
+	var body bytes.Buffer
+	if req != nil {
+		if err := json.NewEncoder(&body).Encode(req); err != nil {
+			return nil, err
+		}
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, cl.URL+path, &body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if accept != "" {
+		httpReq.Header.Set("Accept", accept)
+	}
+	httpResp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't reach server %s: %w", cl.URL, err)
+	}
+	if httpResp.StatusCode >= http.StatusBadRequest {
+		defer httpResp.Body.Close()
+		var errResp ErrorResponse
+		if json.NewDecoder(httpResp.Body).Decode(&errResp) == nil && errResp.Error != "" {
+			return nil, fmt.Errorf("server: %s", errResp.Error)
+		}
+		return nil, fmt.Errorf("server: %s", httpResp.Status)
+	}
+	return httpResp, nil
+}
+
+// status of the server, which is requested once
+func (cl *Client) Health() (*HealthResponse, error) {
+	cl.mu.Lock()
+	health := cl.health
+	cl.mu.Unlock()
+	if health != nil {
+		return health, nil
+	}
+	return cl.fetchHealth()
+}
+
+func (cl *Client) fetchHealth() (*HealthResponse, error) {
+	var health HealthResponse
+	if err := cl.do(http.MethodGet, "/api/v1/health", nil, &health); err != nil {
+		return nil, err
+	}
+	cl.mu.Lock()
+	cl.health = &health
+	cl.mu.Unlock()
+	return &health, nil
+}
+
+// check if the server answers, other than Health the result isn't cached
+func (cl *Client) Ping() error {
+	_, err := cl.fetchHealth()
+	if err != nil {
+		cl.mu.Lock()
+		cl.health = nil
+		cl.mu.Unlock()
+	}
+	return err
+}
+
+// name of the LLM on the server
+func (cl *Client) Model() string {
+	health, err := cl.Health()
+	if err != nil {
+		return ""
+	}
+	return health.Model
+}
+
+func (cl *Client) GetContextSize() int {
+	health, err := cl.Health()
+	if err != nil {
+		log.Warnf("couldn't get context size: %s", err)
+		return 0
+	}
+	return health.ContextSize
+}
+
+func (cl *Client) ListCollections() []string {
+	var resp CollectionsResponse
+	if err := cl.do(http.MethodGet, "/api/v1/collections", nil, &resp); err != nil {
+		log.Warnf("couldn't list collections: %s", err)
+		return nil
+	}
+	return resp.Collections
+}
+
+func (cl *Client) GetInfos(msg string, collections []string, nrDocs int64) (ret []information.RetSection, err error) {
+	var resp RetrieveResponse
+	err = cl.do(http.MethodPost, "/api/v1/retrieve", RetrieveRequest{
+		Query:       msg,
+		Collections: collections,
+		Limit:       nrDocs,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	for _, sec := range resp.Sections {
+		ret = append(ret, information.RetSection{
+			Dist:    sec.Dist,
+			Hash:    sec.Hash,
+			Index:   sec.Index,
+			Source:  sec.Source.Source,
+			Section: sec.Section,
+		})
+	}
+	return ret, nil
+}
+
+func (cl *Client) Get(id string) (info information.Information, err error) {
+	err = cl.do(http.MethodGet, "/api/v1/documents/"+url.PathEscape(id), nil, &info)
+	return
+}
+
+// return the section with index of the document with the given hash
+func (cl *Client) GetSection(id string, index int) (sec information.Section, err error) {
+	info, err := cl.Get(id)
+	if err != nil {
+		return sec, err
+	}
+	if index < 0 || index >= len(info.Sections) {
+		return sec, fmt.Errorf("document %s has no section %d", id, index)
+	}
+	return info.Sections[index], nil
+}
+
+// let the server answer the prompt, the chunks are sent to resp
+func (cl *Client) SendTaskStream(ctx context.Context, prompt string, resp chan *ollamaconnector.TaskResponse) error {
+	defer close(resp)
+	httpResp, err := cl.send(ctx, http.MethodPost, "/api/v1/generate", GenerateRequest{Prompt: prompt}, "application/x-ndjson")
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	scanner := bufio.NewScanner(httpResp.Body)
+	scanner.Buffer(nil, maxBodySize)
+	for scanner.Scan() {
+		var event AskEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return err
+		}
+		switch event.Type {
+		case ChunkEvent:
+			resp <- &ollamaconnector.TaskResponse{Response: event.Response}
+		case DoneEvent:
+			done := &ollamaconnector.TaskResponse{Done: true}
+			if event.Stats != nil {
+				done.Model = event.Stats.Model
+				done.TotalDuration = event.Stats.TotalDuration
+				done.LoadDuration = event.Stats.LoadDuration
+				done.PromptEvalCount = event.Stats.PromptEvalCount
+				done.PromptEvalDuration = event.Stats.PromptEvalDuration
+				done.EvalCount = event.Stats.EvalCount
+				done.EvalDuration = event.Stats.EvalDuration
+			}
+			resp <- done
+			return nil
+		case ErrorEvent:
+			return errors.New(event.Error)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return errors.New("answer of server ended early")
+}
+
+func (cl *Client) SendTask(ctx context.Context, prompt string) (*ollamaconnector.TaskResponse, error) {
+	ch := make(chan *ollamaconnector.TaskResponse)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- cl.SendTaskStream(ctx, prompt, ch)
+	}()
+	var answer strings.Builder
+	var last *ollamaconnector.TaskResponse
+	for resp := range ch {
+		answer.WriteString(resp.Response)
+		last = resp
+	}
+	if err := <-errCh; err != nil {
+		return nil, err
+	}
+	last.Response = answer.String()
+	return last, nil
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openSUSE/kowalski/internal/app/ollamaconnector"
+)
+
+func TestClientSendTaskStream(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		answer string
+		done   bool
+		err    string
+	}{
+		{"done", http.StatusOK, `{"type":"chunk","response":"Hello"}
+{"type":"chunk","response":" world"}
+{"type":"done","stats":{"model":"llm","eval_count":2}}
+`, "Hello world", true, ""},
+		{"error event", http.StatusOK, `{"type":"chunk","response":"Hello"}
+{"type":"error","error":"generation failed: boom"}
+`, "Hello", false, "generation failed: boom"},
+		{"ended early", http.StatusOK, `{"type":"chunk","response":"Hello"}
+`, "Hello", false, "answer of server ended early"},
+		{"empty", http.StatusOK, "", "", false, "answer of server ended early"},
+		{"broken json", http.StatusOK, "{\n", "", false, "unexpected end of JSON input"},
+		{"error status", http.StatusBadRequest, `{"error":"prompt is empty"}`, "", false, "server: prompt is empty"},
+	}
+	for _, test := range tests {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/v1/generate" {
+				http.NotFound(w, r)
+				return
+			}
+			w.WriteHeader(test.status)
+			fmt.Fprint(w, test.body)
+		}))
+		ch := make(chan *ollamaconnector.TaskResponse)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- NewClient(ts.URL+"/").SendTaskStream(context.Background(), "prompt", ch)
+		}()
+		var answer strings.Builder
+		var last *ollamaconnector.TaskResponse
+		for resp := range ch {
+			answer.WriteString(resp.Response)
+			last = resp
+		}
+		err := <-errCh
+		ts.Close()
+		if test.err == "" && err != nil || test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%s: error %v, want %q", test.name, err, test.err)
+		}
+		if answer.String() != test.answer {
+			t.Errorf("%s: answer %q, want %q", test.name, answer.String(), test.answer)
+		}
+		if done := last != nil && last.Done; done != test.done {
+			t.Errorf("%s: done %v, want %v", test.name, done, test.done)
+		}
+		if test.done && last.EvalCount != 2 {
+			t.Errorf("%s: stats not passed: %+v", test.name, last)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/openSUSE/kowalski/internal/app/ollamaconnector"
+	"github.com/openSUSE/kowalski/internal/pkg/database"
+	"github.com/openSUSE/kowalski/internal/pkg/docbook"
+	"github.com/openSUSE/kowalski/internal/pkg/information"
+	"github.com/openSUSE/kowalski/internal/pkg/version"
+)
+
+// maximal size of a request body, documents can be large
+const maxBodySize = 32 << 20
+
+// number of sections returned by retrieve if no limit is given
+const defaultLimit = 5
+
+func (srv *Server) health(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, HealthResponse{
+		Status:      "ok",
+		Version:     strings.TrimSpace(version.Version),
+		Model:       srv.LLM.LLM,
+		ContextSize: srv.LLM.GetContextSize(),
+		ReadOnly:    srv.DB.IsReadOnly(),
+	})
+}
+
+// collections of the request or all if none were given
+func (srv *Server) selectCollections(collections []string) []string {
+	if len(collections) == 0 {
+		return srv.DB.ListCollections()
+	}
+	return collections
+}
+
+// retrieve the documents and stream the answer
+func (srv *Server) ask(w http.ResponseWriter, r *http.Request) {
+	var req AskRequest
+	if err := readJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Question == "" {
+		writeError(w, http.StatusBadRequest, errors.New("question is empty"))
+		return
+	}
+	prompt, err := srv.DB.GetContext(req.Question, srv.selectCollections(req.Collections), srv.Location,
+		srv.LLM.GetContextSize(), database.OptionWithHistory(req.History))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if prompt.Sources == nil {
+		prompt.Sources = []database.Source{}
+	}
+	srv.stream(w, r, prompt.Prompt, prompt.Sources)
+}
+
+// answer a prompt which was created by the client, e.g. with local files
+func (srv *Server) generate(w http.ResponseWriter, r *http.Request) {
+	var req GenerateRequest
+	if err := readJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Prompt == "" {
+		writeError(w, http.StatusBadRequest, errors.New("prompt is empty"))
+		return
+	}
+	srv.stream(w, r, req.Prompt, nil)
+}
+
+/*
+Stream the answer as newline delimited json, or as server sent events
+if the client accepts text/event-stream. The sources are sent first.
+*/
+func (srv *Server) stream(w http.ResponseWriter, r *http.Request, prompt string, sources []database.Source) {
+	sse := strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	ctrl := http.NewResponseController(w)
+	send := func(event AskEvent) {
+		line, _ := json.Marshal(event)
+		if sse {
+			fmt.Fprintf(w, "data: %s\n\n", line)
+		} else {
+			fmt.Fprintf(w, "%s\n", line)
+		}
+		ctrl.Flush()
+	}
+	if sources != nil {
+		send(AskEvent{Type: SourcesEvent, Sources: sources})
+	}
+	ch := make(chan *ollamaconnector.TaskResponse)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.LLM.SendTaskStream(r.Context(), prompt, ch)
+	}()
+	var last *ollamaconnector.TaskResponse
+	for resp := range ch {
+		last = resp
+		if resp.Response != "" {
+			send(AskEvent{Type: ChunkEvent, Response: resp.Response})
+		}
+	}
+	if err := <-errCh; err != nil {
+		log.Warnf("generation failed: %s", err)
+		send(AskEvent{Type: ErrorEvent, Error: "generation failed: " + err.Error()})
+		return
+	}
+	if last == nil || !last.Done {
+		send(AskEvent{Type: ErrorEvent, Error: "generation stopped before it was done"})
+		return
+	}
+	send(AskEvent{Type: DoneEvent, Stats: last.Stats()})
+}
+
+func (srv *Server) retrieve(w http.ResponseWriter, r *http.Request) {
+	var req RetrieveRequest
+	if err := readJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = defaultLimit
+	}
+	infos, err := srv.DB.GetInfos(req.Query, srv.selectCollections(req.Collections), req.Limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	resp := RetrieveResponse{Sections: []Section{}}
+	for _, info := range infos {
+		sec := info.Section
+		sec.EmbeddingVec = nil
+		resp.Sections = append(resp.Sections, Section{
+			Source: database.Source{
+				Title:  info.Title,
+				Source: info.Source,
+				Hash:   info.Hash,
+				Index:  info.Index,
+				Dist:   info.Dist,
+			},
+			Section: sec,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (srv *Server) collections(w http.ResponseWriter, r *http.Request) {
+	collections := srv.DB.ListCollections()
+	if collections == nil {
+		collections = []string{}
+	}
+	writeJSON(w, http.StatusOK, CollectionsResponse{Collections: collections})
+}
+
+func (srv *Server) documents(w http.ResponseWriter, r *http.Request) {
+	docs, err := srv.DB.List(r.PathValue("collection"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if docs == nil {
+		docs = []database.DocumentInfo{}
+	}
+	writeJSON(w, http.StatusOK, DocumentsResponse{Documents: docs})
+}
+
+func (srv *Server) document(w http.ResponseWriter, r *http.Request) {
+	info, err := srv.DB.Get(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	for i := range info.Sections {
+		info.Sections[i].EmbeddingVec = nil
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (srv *Server) addDocument(w http.ResponseWriter, r *http.Request) {
+	if srv.DB.IsReadOnly() {
+		writeError(w, http.StatusForbidden, errors.New("database is read only"))
+		return
+	}
+	collection := r.PathValue("collection")
+	embedding, err := database.GetEmbedding([]string{collection})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var req AddRequest
+	if err := readJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Source == "" || req.Content == "" {
+		writeError(w, http.StatusBadRequest, errors.New("source and content must be set"))
+		return
+	}
+	// the parsers read from files, so the content has to be written to one
+	tmp, err := os.CreateTemp("", "kowalski-add-*")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.WriteString(req.Content)
+	tmp.Close()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var info information.Information
+	switch req.Format {
+	case "xml", "":
+		embeddingSize, err := srv.LLM.GetEmbeddingSize(embedding)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		info, err = docbook.ParseDocBook(tmp.Name(), embeddingSize)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		// the first section is titled with the file name
+		if len(info.Sections) > 0 && info.Sections[0].Title == tmp.Name() {
+			info.Sections[0].Title = req.Source
+		}
+	case "yaml":
+		info, err = information.ReadCurated(tmp.Name())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown format: %s", req.Format))
+		return
+	}
+	if info.Empty() {
+		writeError(w, http.StatusBadRequest, errors.New("document is empty"))
+		return
+	}
+	info.Source = req.Source
+	if err = srv.DB.AddInformation(collection, info); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, AddResponse{Id: info.Hash})
+}
@@ -0,0 +1,264 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"github.com/openSUSE/kowalski/internal/app/ollamaconnector"
+	"github.com/openSUSE/kowalski/internal/pkg/database"
+)
+
+/*
+OpenAI compatible chat completions, so that existing tools can use the
+knowledge database by changing their base URL. The last user message is
+answered with the retrieved documents, the previous messages are used as
+history and system messages are put in front of the prompt. Only the
+fields needed for this are implemented.
+*/
+
+// content of a message, either a string or a list of text parts
+type openAIContent string
+
+func (content *openAIContent) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		*content = openAIContent(str)
+		return nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return errors.New("content must be a string or a list of parts")
+	}
+	var texts []string
+	for _, part := range parts {
+		if part.Type == "text" {
+			texts = append(texts, part.Text)
+		}
+	}
+	*content = openAIContent(strings.Join(texts, "\n"))
+	return nil
+}
+
+type openAIMessage struct {
+	Role    string        `json:"role"`
+	Content openAIContent `json:"content"`
+}
+
+type openAIRequest struct {
+	Model    string          `json:"model"`
+	Messages []openAIMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+}
+
+type openAIDelta struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+type openAIChoice struct {
+	Index        int          `json:"index"`
+	Message      *openAIDelta `json:"message,omitempty"`
+	Delta        *openAIDelta `json:"delta,omitempty"`
+	FinishReason *string      `json:"finish_reason"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type openAIResponse struct {
+	Id      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []openAIChoice `json:"choices"`
+	Usage   *openAIUsage   `json:"usage,omitempty"`
+	// not part of the OpenAI API, clients ignore it
+	Sources []database.Source `json:"sources,omitempty"`
+}
+
+type openAIModel struct {
+	Id      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+func writeOpenAIError(w http.ResponseWriter, status int, err error) {
+	errType := "invalid_request_error"
+	if status >= http.StatusInternalServerError {
+		errType = "server_error"
+	}
+	writeJSON(w, status, map[string]any{
+		"error": map[string]string{
+			"message": err.Error(),
+			"type":    errType,
+		},
+	})
+}
+
+func (srv *Server) models(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"object": "list",
+		"data": []openAIModel{{
+			Id:      srv.LLM.LLM,
+			Object:  "model",
+			Created: time.Now().Unix(),
+			OwnedBy: "kowalski",
+		}},
+	})
+}
+
+// split the messages into the question, the previous turns and the system messages
+func openAIHistory(messages []openAIMessage) (question string, history []database.Turn, system string, err error) {
+	last := -1
+	for i, msg := range messages {
+		if msg.Role == "user" {
+			last = i
+		}
+	}
+	if last < 0 || messages[last].Content == "" {
+		return "", nil, "", errors.New("no user message found")
+	}
+	var systems []string
+	for _, msg := range messages {
+		if (msg.Role == "system" || msg.Role == "developer") && msg.Content != "" {
+			systems = append(systems, string(msg.Content))
+		}
+	}
+	var turn *database.Turn
+	for _, msg := range messages[:last] {
+		switch msg.Role {
+		case "user":
+			history = append(history, database.Turn{Question: string(msg.Content)})
+			turn = &history[len(history)-1]
+		case "assistant":
+			if turn != nil {
+				turn.Answer += string(msg.Content)
+			}
+		}
+	}
+	return string(messages[last].Content), history, strings.Join(systems, "\n"), nil
+}
+
+func (srv *Server) chatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req openAIRequest
+	// clients send many options, which are ignored
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&req); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err)
+		return
+	}
+	question, history, system, err := openAIHistory(req.Messages)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err)
+		return
+	}
+	prompt, err := srv.DB.GetContext(question, srv.DB.ListCollections(), srv.Location,
+		srv.LLM.GetContextSize(), database.OptionWithHistory(history))
+	if err != nil {
+		writeOpenAIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if system != "" {
+		prompt.Prompt = system + "\n\n" + prompt.Prompt
+	}
+	resp := openAIResponse{
+		Id:      "chatcmpl-" + uuid.New().String(),
+		Created: time.Now().Unix(),
+		Model:   srv.LLM.LLM,
+		Sources: prompt.Sources,
+	}
+	ch := make(chan *ollamaconnector.TaskResponse)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.LLM.SendTaskStream(r.Context(), prompt.Prompt, ch)
+	}()
+	if req.Stream {
+		srv.streamCompletion(w, resp, ch, errCh)
+		return
+	}
+	var answer strings.Builder
+	var last *ollamaconnector.TaskResponse
+	for chunk := range ch {
+		answer.WriteString(chunk.Response)
+		last = chunk
+	}
+	if err := <-errCh; err != nil {
+		log.Warnf("generation failed: %s", err)
+		writeOpenAIError(w, http.StatusBadGateway, fmt.Errorf("generation failed: %w", err))
+		return
+	}
+	if last == nil || !last.Done {
+		writeOpenAIError(w, http.StatusBadGateway, errors.New("generation stopped before it was done"))
+		return
+	}
+	stop := "stop"
+	resp.Object = "chat.completion"
+	resp.Choices = []openAIChoice{{
+		Message:      &openAIDelta{Role: "assistant", Content: answer.String()},
+		FinishReason: &stop,
+	}}
+	resp.Usage = &openAIUsage{
+		PromptTokens:     last.PromptEvalCount,
+		CompletionTokens: last.EvalCount,
+		TotalTokens:      last.PromptEvalCount + last.EvalCount,
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// send the answer as server sent events in the chunk format of OpenAI
+func (srv *Server) streamCompletion(w http.ResponseWriter, resp openAIResponse, ch chan *ollamaconnector.TaskResponse, errCh chan error) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	ctrl := http.NewResponseController(w)
+	resp.Object = "chat.completion.chunk"
+	send := func(delta openAIDelta, finish *string) {
+		resp.Choices = []openAIChoice{{Delta: &delta, FinishReason: finish}}
+		line, _ := json.Marshal(resp)
+		fmt.Fprintf(w, "data: %s\n\n", line)
+		ctrl.Flush()
+		// the sources are only sent with the first chunk
+		resp.Sources = nil
+	}
+	send(openAIDelta{Role: "assistant"}, nil)
+	done := false
+	for chunk := range ch {
+		if chunk.Response != "" {
+			send(openAIDelta{Content: chunk.Response}, nil)
+		}
+		done = chunk.Done
+	}
+	err := <-errCh
+	if err != nil {
+		err = fmt.Errorf("generation failed: %w", err)
+	} else if !done {
+		err = errors.New("generation stopped before it was done")
+	}
+	if err != nil {
+		// like OpenAI, an error object instead of the last chunk
+		log.Warnf("generation failed: %s", err)
+		line, _ := json.Marshal(map[string]any{
+			"error": map[string]string{
+				"message": err.Error(),
+				"type":    "server_error",
+			},
+		})
+		fmt.Fprintf(w, "data: %s\n\n", line)
+	} else {
+		finish := "stop"
+		send(openAIDelta{}, &finish)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	ctrl.Flush()
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/openSUSE/kowalski/internal/pkg/database"
+)
+
+func TestOpenAIHistory(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages string
+		question string
+		history  []database.Turn
+		system   string
+		err      bool
+	}{
+		{"question only", `[{"role":"user","content":"vim?"}]`, "vim?", nil, "", false},
+		{"system and developer", `[
+			{"role":"system","content":"be short"},
+			{"role":"user","content":"vim?"},
+			{"role":"developer","content":"use zypper"}]`,
+			"vim?", nil, "be short\nuse zypper", false},
+		{"history", `[
+			{"role":"user","content":"emacs?"},
+			{"role":"assistant","content":"zypper in emacs"},
+			{"role":"user","content":"vim?"}]`,
+			"vim?", []database.Turn{{Question: "emacs?", Answer: "zypper in emacs"}}, "", false},
+		{"assistant before the first user", `[
+			{"role":"assistant","content":"How can I help?"},
+			{"role":"user","content":"emacs?"},
+			{"role":"assistant","content":"zypper in emacs"},
+			{"role":"user","content":"vim?"}]`,
+			"vim?", []database.Turn{{Question: "emacs?", Answer: "zypper in emacs"}}, "", false},
+		{"messages after the question", `[
+			{"role":"user","content":"vim?"},
+			{"role":"assistant","content":"zypper in vim"}]`,
+			"vim?", nil, "", false},
+		{"content parts", `[{"role":"user","content":[
+			{"type":"text","text":"vim?"},
+			{"type":"image_url","image_url":{"url":"x"}},
+			{"type":"text","text":"on Tumbleweed"}]}]`,
+			"vim?\non Tumbleweed", nil, "", false},
+		{"no user", `[{"role":"system","content":"be short"}]`, "", nil, "", true},
+		{"empty question", `[{"role":"user","content":"vim?"},{"role":"user","content":""}]`, "", nil, "", true},
+	}
+	for _, test := range tests {
+		var messages []openAIMessage
+		if err := json.Unmarshal([]byte(test.messages), &messages); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		question, history, system, err := openAIHistory(messages)
+		if (err != nil) != test.err {
+			t.Errorf("%s: error %v", test.name, err)
+			continue
+		}
+		if question != test.question || system != test.system || fmt.Sprint(history) != fmt.Sprint(test.history) {
+			t.Errorf("%s: got %q %v %q, want %q %v %q", test.name, question, history, system, test.question, test.history, test.system)
+		}
+	}
+}
+
+func TestChatCompletions(t *testing.T) {
+	ts := testServer(t)
+	request := func(question string, stream bool) string {
+		return fmt.Sprintf(`{"model":"any","stream":%v,"temperature":0.5,"messages":[{"role":"user","content":%q}]}`, stream, question)
+	}
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		answer string
+		err    string
+	}{
+		{"answer", request("vim?", false), http.StatusOK, "Hello world", ""},
+		{"failing", request("FAIL", false), http.StatusBadGateway, "", "generation failed"},
+		{"cut", request("CUT", false), http.StatusBadGateway, "", "generation stopped before it was done"},
+		{"no question", `{"messages":[]}`, http.StatusBadRequest, "", "no user message found"},
+		{"broken json", `{"messages":`, http.StatusBadRequest, "", "unexpected EOF"},
+	}
+	for _, test := range tests {
+		resp := post(t, ts.URL+"/v1/chat/completions", test.body, "")
+		if resp.StatusCode != test.status {
+			t.Errorf("%s: status %d, want %d", test.name, resp.StatusCode, test.status)
+		}
+		var body struct {
+			openAIResponse
+			Error struct {
+				Message string `json:"message"`
+				Type    string `json:"type"`
+			} `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if test.err != "" {
+			if !strings.Contains(body.Error.Message, test.err) {
+				t.Errorf("%s: error %q, want %q", test.name, body.Error.Message, test.err)
+			}
+			continue
+		}
+		if len(body.Choices) != 1 || body.Choices[0].Message.Content != test.answer || *body.Choices[0].FinishReason != "stop" {
+			t.Errorf("%s: choices %+v", test.name, body.Choices)
+		}
+		if body.Usage == nil || body.Usage.TotalTokens != 12 || len(body.Sources) != 1 {
+			t.Errorf("%s: usage %+v, sources %+v", test.name, body.Usage, body.Sources)
+		}
+	}
+}
+
+func TestChatCompletionsStream(t *testing.T) {
+	ts := testServer(t)
+	tests := []struct {
+		name   string
+		prompt string
+		answer string
+		err    string
+	}{
+		{"answer", "vim?", "Hello world", ""},
+		{"failing", "FAIL", "", "generation failed"},
+		{"cut", "CUT", "Hello world", "generation stopped before it was done"},
+	}
+	for _, test := range tests {
+		body := fmt.Sprintf(`{"stream":true,"messages":[{"role":"user","content":%q}]}`, test.prompt)
+		resp := post(t, ts.URL+"/v1/chat/completions", body, "")
+		if content := resp.Header.Get("Content-Type"); resp.StatusCode != http.StatusOK || content != "text/event-stream" {
+			t.Errorf("%s: status %d, content type %s", test.name, resp.StatusCode, content)
+		}
+		var answer strings.Builder
+		var finish, errMsg string
+		var lines []string
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			lines = append(lines, line)
+			if line == "[DONE]" {
+				continue
+			}
+			var chunk struct {
+				openAIResponse
+				Error *struct {
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+				t.Fatalf("%s: %q: %s", test.name, line, err)
+			}
+			if chunk.Error != nil {
+				errMsg = chunk.Error.Message
+				continue
+			}
+			if len(lines) == 1 && (chunk.Object != "chat.completion.chunk" || len(chunk.Sources) != 1) {
+				t.Errorf("%s: first chunk %+v", test.name, chunk.openAIResponse)
+			}
+			choice := chunk.Choices[0]
+			answer.WriteString(choice.Delta.Content)
+			if choice.FinishReason != nil {
+				finish = *choice.FinishReason
+			}
+		}
+		if len(lines) == 0 || lines[len(lines)-1] != "[DONE]" {
+			t.Errorf("%s: stream doesn't end with [DONE]: %v", test.name, lines)
+		}
+		if answer.String() != test.answer {
+			t.Errorf("%s: answer %q, want %q", test.name, answer.String(), test.answer)
+		}
+		if test.err == "" && (finish != "stop" || errMsg != "") {
+			t.Errorf("%s: finish %q, error %q", test.name, finish, errMsg)
+		}
+		if test.err != "" && (finish != "" || !strings.Contains(errMsg, test.err)) {
+			t.Errorf("%s: finish %q, error %q, want %q", test.name, finish, errMsg, test.err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+/*
+HTTP server which shares one knowledge database between clients. The
+stores are opened once, Knowledge takes care of concurrent access.
+*/
+package server
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/openSUSE/kowalski/internal/app/ollamaconnector"
+	"github.com/openSUSE/kowalski/internal/pkg/database"
+	"github.com/openSUSE/kowalski/internal/pkg/file"
+)
+
+//go:embed schema.json
+var schema []byte
+
+// time the running requests get to finish on shutdown
+const shutdownTimeout = 10 * time.Second
+
+type Server struct {
+	DB  *database.Knowledge
+	LLM *ollamaconnector.Settings
+	// files presented to the LLM for ask requests
+	Location file.Location
+}
+
+func (srv *Server) Handler() http.Handler {
+	if srv.Location == nil {
+		srv.Location = file.Empty{}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/health", srv.health)
+	mux.HandleFunc("GET /api/v1/schema", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/schema+json")
+		w.Write(schema)
+	})
+	mux.HandleFunc("POST /api/v1/ask", srv.ask)
+	mux.HandleFunc("POST /api/v1/generate", srv.generate)
+	mux.HandleFunc("POST /api/v1/retrieve", srv.retrieve)
+	mux.HandleFunc("GET /api/v1/collections", srv.collections)
+	mux.HandleFunc("GET /api/v1/collections/{collection}/documents", srv.documents)
+	mux.HandleFunc("POST /api/v1/collections/{collection}/documents", srv.addDocument)
+	mux.HandleFunc("GET /api/v1/documents/{id}", srv.document)
+	mux.HandleFunc("GET /v1/models", srv.models)
+	mux.HandleFunc("POST /v1/chat/completions", srv.chatCompletions)
+	return logRequests(mux)
+}
+
+/*
+Serve on addr until the context is done, running requests are given
+some time to finish.
+*/
+func (srv *Server) Run(ctx context.Context, addr string) error {
+	// get the context size once, so that it isn't requested concurrently
+	srv.LLM.GetContextSize()
+	httpSrv := &http.Server{
+		Addr:    addr,
+		Handler: srv.Handler(),
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		log.Infof("listening on %s", addr)
+		errCh <- httpSrv.ListenAndServe()
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	log.Infof("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := httpSrv.Shutdown(shutdownCtx)
+	if lerr := <-errCh; !errors.Is(lerr, http.ErrServerClosed) {
+		return lerr
+	}
+	return err
+}
+
+// keeps the status of the response for logging
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// allow http.ResponseController to flush streamed answers
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		log.Infof("%s %s %s %d %s", r.RemoteAddr, r.Method, r.URL.Path, rec.status, time.Since(start).Round(time.Millisecond))
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, val any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(val); err != nil {
+		log.Warnf("couldn't write response: %s", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, ErrorResponse{Error: err.Error()})
+}
+
+// decode the json body into val, unknown fields are rejected
+func readJSON(w http.ResponseWriter, r *http.Request, val any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+	return dec.Decode(val)
+}
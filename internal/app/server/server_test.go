@@ -0,0 +1,178 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openSUSE/kowalski/internal/app/ollamaconnector"
+	"github.com/openSUSE/kowalski/internal/pkg/database"
+	"github.com/openSUSE/kowalski/internal/pkg/information"
+)
+
+/*
+Answers like ollama, the answer is "Hello world". Prompts containing FAIL
+get an error status and prompts containing CUT end before they are done.
+*/
+func fakeOllama(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/tags", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"models":[{"name":"llm:latest"},{"name":"emb:latest"}]}`)
+	})
+	mux.HandleFunc("POST /api/show", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"model_info":{"general.architecture":"test","test.embedding_length":4,"test.context_length":8192}}`)
+	})
+	mux.HandleFunc("POST /api/embed", func(w http.ResponseWriter, r *http.Request) {
+		var req ollamaconnector.EmbeddingRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		var resp ollamaconnector.EmbeddingResponse
+		for range req.Input {
+			resp.Embeddings = append(resp.Embeddings, []float32{1, 0, 0, 0})
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+	mux.HandleFunc("POST /api/generate", func(w http.ResponseWriter, r *http.Request) {
+		var req ollamaconnector.TaskRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if strings.Contains(req.Prompt, "FAIL") {
+			http.Error(w, "model crashed", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintln(w, `{"response":"Hello"}`)
+		fmt.Fprintln(w, `{"response":" world"}`)
+		if !strings.Contains(req.Prompt, "CUT") {
+			fmt.Fprintln(w, `{"model":"llm","done":true,"prompt_eval_count":10,"eval_count":2}`)
+		}
+	})
+	fake := httptest.NewServer(mux)
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+// kowalski server with a collection holding one document
+func testServer(t *testing.T) *httptest.Server {
+	ollamaconnector.Ollamasettings = ollamaconnector.Settings{
+		LLM:       "llm",
+		OllamaURL: fakeOllama(t).URL,
+	}
+	db, err := database.New(database.OptionWithFile(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+	err = db.AddInformation("docs@emb", information.Information{
+		Hash:   "doc1",
+		Source: "install.xml",
+		Sections: []information.Section{{
+			Title: "Installing packages",
+			Lines: []information.Line{{Text: "Use zypper in to install a package."}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := Server{DB: db, LLM: &ollamaconnector.Ollamasettings}
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// the events of a newline delimited or server sent event stream
+func readEvents(t *testing.T, resp *http.Response) (events []AskEvent) {
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := strings.TrimPrefix(scanner.Text(), "data: ")
+		if line == "" {
+			continue
+		}
+		var event AskEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("%q: %s", line, err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func post(t *testing.T, url string, body string, accept string) *http.Response {
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestStream(t *testing.T) {
+	ts := testServer(t)
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		accept  string
+		status  int
+		content string
+		types   []string
+		errMsg  string
+	}{
+		{"ask", "/api/v1/ask", `{"question":"How do I install vim?"}`, "", http.StatusOK, "application/x-ndjson",
+			[]string{SourcesEvent, ChunkEvent, ChunkEvent, DoneEvent}, ""},
+		{"ask sse", "/api/v1/ask", `{"question":"How do I install vim?"}`, "text/event-stream", http.StatusOK, "text/event-stream",
+			[]string{SourcesEvent, ChunkEvent, ChunkEvent, DoneEvent}, ""},
+		{"ask failing", "/api/v1/ask", `{"question":"FAIL"}`, "", http.StatusOK, "application/x-ndjson",
+			[]string{SourcesEvent, ErrorEvent}, "generation failed"},
+		{"ask empty", "/api/v1/ask", `{"question":""}`, "", http.StatusBadRequest, "application/json", nil, ""},
+		{"ask unknown field", "/api/v1/ask", `{"query":"vim"}`, "", http.StatusBadRequest, "application/json", nil, ""},
+		{"generate", "/api/v1/generate", `{"prompt":"say hello"}`, "", http.StatusOK, "application/x-ndjson",
+			[]string{ChunkEvent, ChunkEvent, DoneEvent}, ""},
+		{"generate failing", "/api/v1/generate", `{"prompt":"FAIL"}`, "", http.StatusOK, "application/x-ndjson",
+			[]string{ErrorEvent}, "generation failed"},
+		{"generate cut", "/api/v1/generate", `{"prompt":"CUT"}`, "", http.StatusOK, "application/x-ndjson",
+			[]string{ChunkEvent, ChunkEvent, ErrorEvent}, "generation stopped before it was done"},
+		{"generate empty", "/api/v1/generate", `{}`, "", http.StatusBadRequest, "application/json", nil, ""},
+	}
+	for _, test := range tests {
+		resp := post(t, ts.URL+test.path, test.body, test.accept)
+		if resp.StatusCode != test.status {
+			t.Errorf("%s: status %d, want %d", test.name, resp.StatusCode, test.status)
+		}
+		if content := resp.Header.Get("Content-Type"); content != test.content {
+			t.Errorf("%s: content type %s, want %s", test.name, content, test.content)
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+		events := readEvents(t, resp)
+		var types []string
+		var answer strings.Builder
+		for _, event := range events {
+			types = append(types, event.Type)
+			answer.WriteString(event.Response)
+		}
+		if fmt.Sprint(types) != fmt.Sprint(test.types) {
+			t.Errorf("%s: events %v, want %v", test.name, types, test.types)
+			continue
+		}
+		first, last := events[0], events[len(events)-1]
+		if first.Type == SourcesEvent && (len(first.Sources) != 1 || first.Sources[0].Source != "install.xml") {
+			t.Errorf("%s: sources %+v", test.name, first.Sources)
+		}
+		if last.Type == DoneEvent && (answer.String() != "Hello world" || last.Stats == nil || last.Stats.EvalCount != 2) {
+			t.Errorf("%s: answer %q, stats %+v", test.name, answer.String(), last.Stats)
+		}
+		if last.Type == ErrorEvent && !strings.Contains(last.Error, test.errMsg) {
+			t.Errorf("%s: error %q, want %q", test.name, last.Error, test.errMsg)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+/*
+Render the templates with sample data, so that an override which uses
+unknown fields or functions is found before it's used for a prompt.
+*/
+package templatecheck
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/openSUSE/kowalski/internal/pkg/actions"
+	"github.com/openSUSE/kowalski/internal/pkg/database"
+	"github.com/openSUSE/kowalski/internal/pkg/information"
+	"github.com/openSUSE/kowalski/internal/pkg/templates"
+)
+
+// used if no question is given
+const DefaultQuestion = "How do I enable the ssh server?"
+
+var sampleSection = information.Section{
+	Title: "Enabling the SSH service",
+	Lines: []information.Line{
+		{Text: "Enabling the SSH service", Type: information.Title},
+		{Text: "The SSH daemon is disabled after the installation, enable and start it with", Type: information.Text},
+		{Text: "systemctl enable --now sshd", Type: information.Command},
+		{Text: "/etc/ssh/sshd_config", Type: information.File},
+	},
+	Files:    []string{"/etc/ssh/sshd_config"},
+	Commands: []string{"systemctl"},
+}
+
+var sampleHistory = []database.Turn{{
+	Question: "Which package contains the ssh server?",
+	Answer:   "The ssh server is in the package openssh-server.",
+}}
+
+var sampleResults = []actions.Result{{
+	Command:  "systemctl enable --now sshd",
+	Stdout:   "Created symlink /etc/systemd/system/multi-user.target.wants/sshd.service",
+	Duration: 120 * time.Millisecond,
+}}
+
+func sampleFile(path string) string {
+	return path + ":\nPermitRootLogin prohibit-password\n"
+}
+
+// render the template name given as text with sample data for the question
+func Render(name, text, question string) (string, error) {
+	if question == "" {
+		question = DefaultQuestion
+	}
+	switch name {
+	case templates.PromptName:
+		context, err := sampleSection.Render(templates.RenderInfo, map[string]func(string) string{"FileInfo": sampleFile})
+		if err != nil {
+			return "", err
+		}
+		info := database.GetSystemInfo()
+		info.Task = question
+		info.Context = context
+		info.History = database.FormatHistory(sampleHistory)
+		return execute(text, sprig.FuncMap(), info)
+	case templates.AgentPromptName:
+		return execute(text, nil, database.GetSystemInfo())
+	case templates.RewriteQueryName:
+		return execute(text, nil, database.RewriteInfo{
+			History: database.FormatHistory(sampleHistory),
+			Task:    question,
+			Multi:   true,
+		})
+	case templates.CommandResultsName:
+		return execute(text, nil, sampleResults)
+	case templates.RenderInfoName:
+		// like in the context of the prompt
+		return sampleSection.Render(text, map[string]func(string) string{"FileInfo": sampleFile})
+	case templates.RenderInfoWithMetaName:
+		return sampleSection.Render(text, map[string]func(string) string{"RenderFile": sampleFile})
+	case templates.RenderTitleOnlyName:
+		info := information.Information{
+			Source:   "sample.xml",
+			Sections: []information.Section{sampleSection},
+			Files:    sampleSection.Files,
+			Commands: sampleSection.Commands,
+		}
+		return info.Render(text)
+	}
+	return "", fmt.Errorf("unknown template: %s", name)
+}
+
+func execute(text string, funcs template.FuncMap, data any) (string, error) {
+	tmpl, err := template.New("check").Funcs(funcs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, data)
+	return buf.String(), err
+}
+
+// the template must parse and render with the sample data
+func Check(name, text string) error {
+	_, err := Render(name, text, "")
+	return err
+}
@@ -0,0 +1,168 @@
+/*
+Parse the answers of the LLM for the actions it proposes. The prompt asks
+the modell to wrap shell commands in <command>...</command> and new
+configuration files in <file id=filename>...</file>, but small modells
+often forget to close the tags or quote the attributes, so the parser
+is tolerant and just marks such actions as incomplete.
+*/
+package actions
+
+import (
+	"regexp"
+	"strings"
+)
+
+type ActionType string
+
+const (
+	CommandAction ActionType = "command"
+	FileAction    ActionType = "file"
+)
+
+// shell command proposed by the LLM
+type Command struct {
+	Command  string `json:"command" yaml:"command"`
+	Complete bool   `json:"complete" yaml:"complete"`
+}
+
+// file with its new content proposed by the LLM
+type File struct {
+	Path     string `json:"path" yaml:"path"`
+	Content  string `json:"content" yaml:"content"`
+	Complete bool   `json:"complete" yaml:"complete"`
+}
+
+// all the actions of an answer, in the order they appeared
+type Plan struct {
+	Commands []Command   `json:"commands,omitempty" yaml:"commands,omitempty"`
+	Files    []File      `json:"files,omitempty" yaml:"files,omitempty"`
+	Order    []ActionRef `json:"-" yaml:"-"`
+}
+
+// reference to an action of the plan
+type ActionRef struct {
+	Type  ActionType
+	Index int
+	// position of the action including its tags in the answer
+	Start, End int
+}
+
+func (plan *Plan) Empty() bool {
+	return len(plan.Commands) == 0 && len(plan.Files) == 0
+}
+
+// matches opening and closing tags, the attribute of file may be quoted or not
+var tagRegEx = regexp.MustCompile(`(?i)<(/?)(command|file)(?:\s+(?:id|path|name)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+)))?\s*>`)
+
+// fenced code blocks the modell sometimes adds inside of the tags
+var fenceRegEx = regexp.MustCompile("(?m)^\\s*```[a-zA-Z0-9_-]*\\s*$")
+
+/*
+Parse the answer for actions. A tag which isn't closed ends at the next
+opening tag or at the end of the answer and is marked as incomplete.
+*/
+func Parse(answer string) (plan Plan) {
+	tags := tagRegEx.FindAllStringSubmatchIndex(answer, -1)
+	for i := 0; i < len(tags); i++ {
+		tag := tags[i]
+		if tag[2] != tag[3] {
+			// closing tag without opening one
+			continue
+		}
+		name := strings.ToLower(answer[tag[4]:tag[5]])
+		start := tag[1]
+		end := len(answer)
+		regionEnd := end
+		complete := false
+		for j := i + 1; j < len(tags); j++ {
+			next := tags[j]
+			isClosing := next[2] != next[3]
+			if isClosing {
+				// accept any closing tag, the modell mixes them up
+				end = next[0]
+				regionEnd = next[1]
+				complete = true
+				i = j
+				break
+			}
+			end = next[0]
+			regionEnd = end
+			i = j - 1
+			break
+		}
+		content := fenceRegEx.ReplaceAllString(answer[start:end], "")
+		switch ActionType(name) {
+		case CommandAction:
+			content = strings.TrimSpace(content)
+			if content == "" {
+				continue
+			}
+			plan.Order = append(plan.Order, ActionRef{Type: CommandAction, Index: len(plan.Commands),
+				Start: tag[0], End: regionEnd})
+			plan.Commands = append(plan.Commands, Command{
+				Command:  content,
+				Complete: complete,
+			})
+		case FileAction:
+			path := ""
+			for _, grp := range []int{6, 8, 10} {
+				if tag[grp] >= 0 {
+					path = answer[tag[grp]:tag[grp+1]]
+				}
+			}
+			plan.Order = append(plan.Order, ActionRef{Type: FileAction, Index: len(plan.Files),
+				Start: tag[0], End: regionEnd})
+			plan.Files = append(plan.Files, File{
+				Path:     strings.TrimSpace(path),
+				Content:  cleanFile(content),
+				Complete: complete && path != "",
+			})
+		}
+	}
+	return
+}
+
+/*
+Replace the actions of the plan, which must be parsed from answer, with
+the result of replace. Tags which don't belong to an action are removed.
+*/
+func Replace(answer string, plan Plan, replace func(ref ActionRef) string) string {
+	var out strings.Builder
+	pos := 0
+	for _, ref := range plan.Order {
+		out.WriteString(tagRegEx.ReplaceAllString(answer[pos:ref.Start], ""))
+		out.WriteString(replace(ref))
+		pos = ref.End
+	}
+	out.WriteString(tagRegEx.ReplaceAllString(answer[pos:], ""))
+	return out.String()
+}
+
+// remove the surrounding empty lines, but keep the indentation
+func cleanFile(str string) string {
+	lines := strings.Split(strings.TrimRight(str, "\n\r \t"), "\n")
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+/*
+Parser collects the chunks of a streamed answer, so that the plan
+can be looked at while the answer is still generated.
+*/
+type Parser struct {
+	buf strings.Builder
+}
+
+func (p *Parser) Write(chunk string) {
+	p.buf.WriteString(chunk)
+}
+
+func (p *Parser) Plan() Plan {
+	return Parse(p.buf.String())
+}
+
+func (p *Parser) String() string {
+	return p.buf.String()
+}
@@ -0,0 +1,84 @@
+package actions
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		answer string
+		plan   Plan
+	}{
+		{"no actions", "just text", Plan{}},
+		{"command", "run <command>systemctl enable sshd</command> now",
+			Plan{Commands: []Command{{Command: "systemctl enable sshd", Complete: true}}}},
+		{"command in a code fence", "<command>\n```bash\nzypper in vim\n```\n</command>",
+			Plan{Commands: []Command{{Command: "zypper in vim", Complete: true}}}},
+		{"unclosed command at the end", "run <command>zypper in",
+			Plan{Commands: []Command{{Command: "zypper in", Complete: false}}}},
+		{"unclosed command before another one", "<command>ls\n<command>pwd</command>",
+			Plan{Commands: []Command{{Command: "ls", Complete: false}, {Command: "pwd", Complete: true}}}},
+		{"empty command", "<command> </command>", Plan{}},
+		{"closing tag without opening one", "text</command> more", Plan{}},
+		{"mixed up closing tag", "<command>ls</file>",
+			Plan{Commands: []Command{{Command: "ls", Complete: true}}}},
+		{"upper case tags", "<COMMAND>ls</COMMAND>",
+			Plan{Commands: []Command{{Command: "ls", Complete: true}}}},
+		{"file with quoted id", "<file id=\"/etc/motd\">\nhello\n</file>",
+			Plan{Files: []File{{Path: "/etc/motd", Content: "hello\n", Complete: true}}}},
+		{"file with unquoted path", "<file path=/etc/motd>\n  indented\n</file>",
+			Plan{Files: []File{{Path: "/etc/motd", Content: "  indented\n", Complete: true}}}},
+		{"file with single quotes", "<file name='/etc/motd'>x</file>",
+			Plan{Files: []File{{Path: "/etc/motd", Content: "x\n", Complete: true}}}},
+		{"file without id", "<file>\nhello\n</file>",
+			Plan{Files: []File{{Path: "", Content: "hello\n", Complete: false}}}},
+		{"unclosed file", "<file id=/etc/motd>\nhello",
+			Plan{Files: []File{{Path: "/etc/motd", Content: "hello\n", Complete: false}}}},
+		{"unterminated tag is text", "<command ls", Plan{}},
+	}
+	for _, test := range tests {
+		plan := Parse(test.answer)
+		// the positions are tested with Replace
+		plan.Order = nil
+		if !reflect.DeepEqual(plan, test.plan) {
+			t.Errorf("%s: got %+v, want %+v", test.name, plan, test.plan)
+		}
+	}
+}
+
+func TestParseOrder(t *testing.T) {
+	plan := Parse("<file id=/a>x</file> then <command>ls</command> and <file id=/b>y</file>")
+	want := []ActionType{FileAction, CommandAction, FileAction}
+	if len(plan.Order) != len(want) {
+		t.Fatalf("got %d actions, want %d", len(plan.Order), len(want))
+	}
+	for i, ref := range plan.Order {
+		if ref.Type != want[i] {
+			t.Errorf("action %d is %s, want %s", i, ref.Type, want[i])
+		}
+	}
+	if plan.Order[2].Index != 1 {
+		t.Errorf("index of the second file is %d, want 1", plan.Order[2].Index)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	tests := []struct {
+		answer string
+		out    string
+	}{
+		{"run <command>ls</command> now", "run [command] now"},
+		{"<file id=/a>x</file><command>ls", "[file][command]"},
+		{"stray </command> tag", "stray  tag"},
+	}
+	for _, test := range tests {
+		out := Replace(test.answer, Parse(test.answer), func(ref ActionRef) string {
+			return "[" + string(ref.Type) + "]"
+		})
+		if out != test.out {
+			t.Errorf("Replace(%q) = %q, want %q", test.answer, out, test.out)
+		}
+	}
+}
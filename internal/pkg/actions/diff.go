@@ -0,0 +1,140 @@
+package actions
+
+import (
+	"fmt"
+	"strings"
+)
+
+// lines of context around a change
+const diffContext = 3
+
+// files with more lines are just shown completely replaced
+const maxDiffLines = 5000
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+/*
+Create a unified diff between the old and new content, the result is
+empty if both are equal.
+*/
+func Diff(oldName, newName, oldContent, newContent string) string {
+	if oldContent == newContent {
+		return ""
+	}
+	oldLines := splitLines(oldContent)
+	newLines := splitLines(newContent)
+	ops := diffLines(oldLines, newLines)
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", oldName, newName)
+	// walk over the operations and collect the hunks
+	oldNr, newNr := 1, 1
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			oldNr++
+			newNr++
+			i++
+			continue
+		}
+		start := max(0, i-diffContext)
+		hunkOld := oldNr - (i - start)
+		hunkNew := newNr - (i - start)
+		end := i
+		// extend hunk while changes are closer than two times the context
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j
+			} else if j-end > 2*diffContext {
+				break
+			}
+		}
+		end = min(len(ops), end+diffContext+1)
+		var lines []string
+		oldCount, newCount := 0, 0
+		for _, op := range ops[start:end] {
+			lines = append(lines, string(op.kind)+op.line)
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+		// empty ranges start at the line before, like in diff(1)
+		if oldCount == 0 {
+			hunkOld--
+		}
+		if newCount == 0 {
+			hunkNew--
+		}
+		fmt.Fprintf(&buf, "@@ -%d,%d +%d,%d @@\n", hunkOld, oldCount, hunkNew, newCount)
+		buf.WriteString(strings.Join(lines, "\n") + "\n")
+		for _, op := range ops[i:end] {
+			if op.kind != '+' {
+				oldNr++
+			}
+			if op.kind != '-' {
+				newNr++
+			}
+		}
+		i = end
+	}
+	return buf.String()
+}
+
+func splitLines(str string) []string {
+	if str == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(str, "\n"), "\n")
+}
+
+// longest common subsequence of the lines
+func diffLines(a, b []string) (ops []diffOp) {
+	if len(a)+len(b) > maxDiffLines {
+		for _, line := range a {
+			ops = append(ops, diffOp{'-', line})
+		}
+		for _, line := range b {
+			ops = append(ops, diffOp{'+', line})
+		}
+		return
+	}
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return
+}
@@ -0,0 +1,98 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// output of a command is truncated after this many bytes
+const maxOutput = 8192
+
+const defaultTimeout = 2 * time.Minute
+
+// outcome of an executed command
+type Result struct {
+	Command  string        `json:"command" yaml:"command"`
+	Stdout   string        `json:"stdout" yaml:"stdout"`
+	Stderr   string        `json:"stderr" yaml:"stderr"`
+	ExitCode int           `json:"exit_code" yaml:"exit_code"`
+	Duration time.Duration `json:"duration" yaml:"duration"`
+	DryRun   bool          `json:"dry_run,omitempty" yaml:"dry_run,omitempty"`
+}
+
+// executes commands which pass the policy, inside of Chroot if set
+type Runner struct {
+	Policy  *Policy
+	Chroot  string
+	DryRun  bool
+	Timeout time.Duration
+}
+
+// returns an error if the command may not be run, also without a policy
+func (runner Runner) Check(command string) error {
+	if runner.Policy == nil {
+		return errors.New("no policy for running commands")
+	}
+	return runner.Policy.Check(command)
+}
+
+/*
+Run the command with /bin/sh. An error is returned if the policy denies
+the command or it couldn't be started, a failing command is reported
+by the exit code of the result.
+*/
+func (runner Runner) Run(ctx context.Context, command string) (res Result, err error) {
+	res.Command = command
+	if err = runner.Check(command); err != nil {
+		return res, err
+	}
+	if runner.DryRun {
+		res.DryRun = true
+		return res, nil
+	}
+	timeout := runner.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if runner.Chroot != "" && runner.Chroot != "/" {
+		cmd = exec.CommandContext(ctx, "chroot", runner.Chroot, "/bin/sh", "-c", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	}
+	// don't wait for children of the shell which keep the output open
+	cmd.WaitDelay = time.Second
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	start := time.Now()
+	err = cmd.Run()
+	res.Duration = time.Since(start)
+	res.Stdout = truncate(stdout.String())
+	res.Stderr = truncate(stderr.String())
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		res.ExitCode = exitErr.ExitCode()
+		err = nil
+	}
+	// killed because of the timeout or stopped by the user
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	log.Debugf("ran '%s' exit: %d", command, res.ExitCode)
+	return res, err
+}
+
+func truncate(str string) string {
+	if len(str) > maxOutput {
+		return str[:maxOutput] + "\n[output truncated]"
+	}
+	return str
+}
@@ -0,0 +1,70 @@
+package actions
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// commands which are never executed, whatever the configuration says
+var builtinDeny = []string{
+	// rm -rf / and rm -rf /*, also with long options, -- and the options after /
+	`\brm\s+(?:[^\s;&|]+\s+)*?(?:-[a-zA-Z]*[rR][a-zA-Z]*|--recursive)\s+(?:[^\s;&|]+\s+)*/\*?(?:\s|;|&|\||$)`,
+	`\brm\s+(?:[^\s;&|]+\s+)*/\*?\s+(?:[^\s;&|]+\s+)*(?:-[a-zA-Z]*[rR][a-zA-Z]*|--recursive)(?:\s|;|&|\||$)`,
+	`\bfind\s+/\*?\s[^;&|]*-delete\b`,
+	`\brm\s+(?:-\S+\s+)*--no-preserve-root\b`,
+	`\bmkfs(?:\.\w+)?\b`,
+	`\bdd\b.*\bof=/dev/`,
+	`>\s*/dev/[sh]d[a-z]`,
+	`>\s*/dev/nvme`,
+	// download piped into a shell, also with sudo or env and their options
+	`\b(?:curl|wget|fetch)\b.*\|\s*(?:(?:sudo|doas|env)\b[^|;&]*?\s)?(?:\S*/)?(?:ba|z|da|k|fi)?sh(?:\s|;|&|\||$)`,
+	// fork bomb
+	`:\(\)\s*\{.*\};\s*:`,
+}
+
+/*
+Policy decides which commands may be executed. A command is denied if it
+matches the built-in deny list or a configured deny pattern. If allow
+patterns are configured, the command must match one of them.
+*/
+type Policy struct {
+	allow []*regexp.Regexp
+	deny  []*regexp.Regexp
+}
+
+func NewPolicy(allow, deny []string) (*Policy, error) {
+	policy := Policy{}
+	for _, pattern := range allow {
+		regEx, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allow pattern '%s': %w", pattern, err)
+		}
+		policy.allow = append(policy.allow, regEx)
+	}
+	for _, pattern := range append(builtinDeny, deny...) {
+		regEx, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid deny pattern '%s': %w", pattern, err)
+		}
+		policy.deny = append(policy.deny, regEx)
+	}
+	return &policy, nil
+}
+
+// returns an error with the reason if the command isn't allowed
+func (policy *Policy) Check(command string) error {
+	for _, regEx := range policy.deny {
+		if regEx.MatchString(command) {
+			return fmt.Errorf("command denied by pattern '%s'", regEx)
+		}
+	}
+	if len(policy.allow) == 0 {
+		return nil
+	}
+	for _, regEx := range policy.allow {
+		if regEx.MatchString(command) {
+			return nil
+		}
+	}
+	return fmt.Errorf("command doesn't match any allow pattern")
+}
@@ -0,0 +1,97 @@
+package actions
+
+import "testing"
+
+func TestPipeToShell(t *testing.T) {
+	policy, err := NewPolicy(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		command string
+		denied  bool
+	}{
+		{"curl -s https://example.com/install | sh", true},
+		{"curl https://example.com/install|bash", true},
+		{"wget -qO- https://example.com/x | sudo bash", true},
+		{"wget -qO- https://example.com/x | sudo -E bash", true},
+		{"wget -qO- https://example.com/x | sudo -u root -E bash -s", true},
+		{"curl https://example.com/x | env FOO=1 sh", true},
+		{"curl https://example.com/x | env -i /bin/bash", true},
+		{"curl https://example.com/x | doas zsh", true},
+		{"curl https://example.com/x | /usr/bin/sh -", true},
+		{"curl -o /tmp/x https://example.com/x", false},
+		{"curl https://example.com/key | sudo tee /etc/ssh/key", false},
+		{"curl https://example.com/x | sudo tee /etc/zsh/zshrc", false},
+		{"curl https://example.com/x | grep sshd", false},
+	}
+	for _, test := range tests {
+		err := policy.Check(test.command)
+		if denied := err != nil; denied != test.denied {
+			t.Errorf("%q: denied %v, want %v (%v)", test.command, denied, test.denied, err)
+		}
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		allow   []string
+		deny    []string
+		command string
+		allowed bool
+	}{
+		{"no rules", nil, nil, "systemctl restart sshd", true},
+		{"rm -rf /", nil, nil, "rm -rf /", false},
+		{"rm -rf /*", nil, nil, "sudo rm -fr /*", false},
+		{"rm with options before", nil, nil, "rm -v -rf / ", false},
+		{"rm long options", nil, nil, "rm --recursive --force /", false},
+		{"rm separate options", nil, nil, "rm -r -f -- /", false},
+		{"rm options after /", nil, nil, "rm / -rf", false},
+		{"rm / among others", nil, nil, "rm -rf build /", false},
+		{"rm below /", nil, nil, "rm -rf /tmp/build", true},
+		{"rm below / with long options", nil, nil, "rm --recursive --force /tmp/build", true},
+		{"rm and cd /", nil, nil, "rm -rf build; cd /", true},
+		{"not recursive", nil, nil, "rm -f /tmp/x /", true},
+		{"find / -delete", nil, nil, "find / -name '*.tmp' -delete", false},
+		{"find below /", nil, nil, "find /tmp -name '*.tmp' -delete", true},
+		{"no preserve root", nil, nil, "rm --no-preserve-root -r /var", false},
+		{"mkfs", nil, nil, "mkfs.ext4 /dev/sdb1", false},
+		{"dd to a device", nil, nil, "dd if=image.iso of=/dev/sdb bs=4M", false},
+		{"dd to a file", nil, nil, "dd if=/dev/zero of=/tmp/swap bs=1M count=10", true},
+		{"redirect to a disk", nil, nil, "echo x > /dev/sda", false},
+		{"redirect to nvme", nil, nil, "cat x >/dev/nvme0n1", false},
+		{"fork bomb", nil, nil, ":(){ :|:& };:", false},
+		{"allowed", []string{"^zypper ", "^systemctl "}, nil, "zypper in vim", true},
+		{"not allowed", []string{"^zypper ", "^systemctl "}, nil, "reboot", false},
+		{"configured deny", nil, []string{"^reboot"}, "reboot now", false},
+		{"deny wins over allow", []string{"^systemctl "}, []string{"poweroff"}, "systemctl poweroff", false},
+		{"built-in deny wins over allow", []string{"^rm "}, nil, "rm -rf /", false},
+	}
+	for _, test := range tests {
+		policy, err := NewPolicy(test.allow, test.deny)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		err = policy.Check(test.command)
+		if allowed := err == nil; allowed != test.allowed {
+			t.Errorf("%s: %q allowed %v, want %v (%v)", test.name, test.command, allowed, test.allowed, err)
+		}
+	}
+}
+
+func TestInvalidPattern(t *testing.T) {
+	if _, err := NewPolicy([]string{"("}, nil); err == nil {
+		t.Error("invalid allow pattern was accepted")
+	}
+	if _, err := NewPolicy(nil, []string{"["}); err == nil {
+		t.Error("invalid deny pattern was accepted")
+	}
+}
+
+func TestRunnerWithoutPolicy(t *testing.T) {
+	runner := Runner{}
+	if err := runner.Check("ls"); err == nil {
+		t.Error("command was allowed without a policy")
+	}
+}
@@ -0,0 +1,170 @@
+/*
+Append only log of the questions, answers and actions of kowalski, so that
+every change to the system can be traced. Each entry is one json line.
+*/
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openSUSE/kowalski/internal/pkg/xdg"
+)
+
+type EntryType string
+
+const (
+	AnswerEntry  EntryType = "answer"
+	FileEntry    EntryType = "file"
+	CommandEntry EntryType = "command"
+	UndoEntry    EntryType = "undo"
+)
+
+// status of file and command entries
+const (
+	Proposed = "proposed"
+	Applied  = "applied"
+	Rejected = "rejected"
+	Executed = "executed"
+	Denied   = "denied"
+	Failed   = "failed"
+	DryRun   = "dry-run"
+	Restored = "restored"
+	// answer which was stopped by the user
+	Interrupted = "interrupted"
+)
+
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Type     EntryType `json:"type"`
+	Session  string    `json:"session,omitempty"`
+	User     string    `json:"user,omitempty"`
+	Status   string    `json:"status,omitempty"`
+	Question string    `json:"question,omitempty"`
+	Model    string    `json:"model,omitempty"`
+	Sources  []string  `json:"sources,omitempty"`
+	Answer   string    `json:"answer,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Before   string    `json:"before_sha256,omitempty"`
+	After    string    `json:"after_sha256,omitempty"`
+	Backup   string    `json:"backup,omitempty"`
+	Command  string    `json:"command,omitempty"`
+	ExitCode *int      `json:"exit_code,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// path of the audit log, empty disables logging
+var LogPath = defaultPath()
+
+func defaultPath() string {
+	if os.Geteuid() == 0 {
+		return "/var/log/kowalski/audit.jsonl"
+	}
+	return xdg.StateHome("audit.jsonl")
+}
+
+var mutex sync.Mutex
+
+// append the entry to the log, time and user are set if empty
+func Log(entry Entry) error {
+	if LogPath == "" {
+		return nil
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	if entry.User == "" {
+		if usr, err := user.Current(); err == nil {
+			entry.User = usr.Username
+		}
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if err = os.MkdirAll(filepath.Dir(LogPath), 0700); err != nil {
+		return err
+	}
+	fh, err := os.OpenFile(LogPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	// a single write, so that concurrent writers don't mix their lines
+	_, err = fh.Write(append(line, '\n'))
+	return err
+}
+
+// sha256 of the content, empty content results in an empty checksum
+func Checksum(content string) string {
+	if content == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// select entries of the log, empty fields match everything
+type Filter struct {
+	Type     EntryType
+	Session  string
+	Status   string
+	Since    time.Time
+	Until    time.Time
+	Contains string
+}
+
+func (filter Filter) Match(entry Entry) bool {
+	switch {
+	case filter.Type != "" && entry.Type != filter.Type:
+		return false
+	case filter.Session != "" && !strings.HasPrefix(entry.Session, filter.Session):
+		return false
+	case filter.Status != "" && entry.Status != filter.Status:
+		return false
+	case !filter.Since.IsZero() && entry.Time.Before(filter.Since):
+		return false
+	case !filter.Until.IsZero() && entry.Time.After(filter.Until):
+		return false
+	}
+	if filter.Contains != "" {
+		line, _ := json.Marshal(entry)
+		return strings.Contains(strings.ToLower(string(line)), strings.ToLower(filter.Contains))
+	}
+	return true
+}
+
+// read the entries of the log which match the filter
+func Read(filter Filter) (entries []Entry, err error) {
+	fh, err := os.Open(LogPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	scanner := bufio.NewScanner(fh)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if filter.Match(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
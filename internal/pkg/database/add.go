@@ -31,20 +31,12 @@ func (kn *Knowledge) AddInformation(collection string, info information.Informat
 		return errors.New("wrong collection format must be 'name@embeddingmodell'")
 	}
 	embeddingName := collectionSplit[1]
-	if _, ok := kn.db[collection]; !ok {
-		log.Debugf("creating new db for collection: %s", collection)
-		err = os.MkdirAll(kn.dbPath, 0755)
-		if err != nil {
-			return err
-		}
-		newStore, err := bolthold.Open(path.Join(kn.dbPath, collection+dbSuffix), 0644, kn.boltOpts)
-		if err != nil {
-			return err
-		}
-		kn.db[collection] = newStore
+	store, err := kn.openStore(collection)
+	if err != nil {
+		return err
 	}
 	log.Debugf("counting in collection: %s", collection)
-	count, err := kn.db[collection].Count(&info, bolthold.Where("Hash").Eq(info.Hash))
+	count, err := store.Count(&info, bolthold.Where("Hash").Eq(info.Hash))
 	if err != nil {
 		return err
 	}
@@ -53,13 +45,14 @@ func (kn *Knowledge) AddInformation(collection string, info information.Informat
 		if err != nil {
 			return err
 		}
-		err = kn.db[collection].Insert(info.Hash, info)
-		/* Do not add to faiss right now, as the index isn't stored
-		err := kn.faissIndex.Add(info.EmbeddingVec)
+		err = store.Insert(info.Hash, info)
 		if err != nil {
 			return err
 		}
-		*/
+		// the index isn't stored, so just recreate it with the next search
+		kn.mutex.Lock()
+		kn.resetIndex()
+		kn.mutex.Unlock()
 		log.Infof("added '%s' with id: %s", info.Source, info.Hash)
 	} else {
 		log.Infof("found document '%s': %s ", info.Source, info.Hash)
@@ -67,69 +60,108 @@ func (kn *Knowledge) AddInformation(collection string, info information.Informat
 	return nil
 }
 
+// get the store of the collection, which is created if it doesn't exist
+func (kn *Knowledge) openStore(collection string) (*bolthold.Store, error) {
+	kn.mutex.Lock()
+	defer kn.mutex.Unlock()
+	if store, ok := kn.db[collection]; ok {
+		return store, nil
+	}
+	log.Debugf("creating new db for collection: %s", collection)
+	err := os.MkdirAll(kn.dbPath, 0755)
+	if err != nil {
+		return nil, err
+	}
+	newStore, err := bolthold.Open(path.Join(kn.dbPath, collection+DBSuffix), 0644, kn.boltOpts)
+	if err != nil {
+		return nil, err
+	}
+	kn.db[collection] = newStore
+	return newStore, nil
+}
+
 // Get the infos out of the database for the given question. The returned documents only
-// contain this section
+// contain this section and are diversified with maximal marginal relevance
 func (kn *Knowledge) GetInfos(question string, collections []string, nrDocs int64) (documents []information.RetSection, err error) {
 	embedding, err := GetEmbedding(collections)
 	if err != nil {
 		return documents, err
 	}
-	kn.CreateIndex()
 	emb, err := ollamaconnector.Ollamasettings.GetEmbeddings([]string{question}, embedding)
 	if err != nil {
 		return nil, err
 	}
-	lengthVec, indexVec, err := kn.faissIndex.Search(emb.Embeddings[0], nrDocs)
+	if len(emb.Embeddings) == 0 {
+		return nil, errors.New("couldn't calculate embedding of question")
+	}
+	kn.mutex.Lock()
+	if err = kn.createIndex(); err != nil {
+		kn.mutex.Unlock()
+		return nil, err
+	}
+	lengthVec, indexVec, err := kn.faissIndex.Search(emb.Embeddings[0], nrDocs*mmrFetchFactor)
 	if err != nil {
+		kn.mutex.Unlock()
 		return nil, err
 	}
+	faissIds := make([]string, len(indexVec))
 	for i, indx := range indexVec {
 		if indx >= 0 && indx < int64(len(kn.faissId)) {
-			// the faiss index vector has following format "hash:index" where
-			// index refers to the section, so we have to split up
-			var id []string
-			var sectIndex int
-			var info information.Information
-			if len(collections) == 0 {
-				collections = kn.ListCollections()
+			faissIds[i] = kn.faissId[indx]
+		}
+	}
+	kn.mutex.Unlock()
+	kn.mutex.RLock()
+	defer kn.mutex.RUnlock()
+	for i, faissId := range faissIds {
+		if faissId == "" {
+			continue
+		}
+		// the faiss index vector has following format "hash:index" where
+		// index refers to the section, so we have to split up
+		id := strings.Split(faissId, ":")
+		if len(id) != 2 {
+			return nil, errors.New("document id in faiss index has wrong format")
+		}
+		sectIndex, err := strconv.Atoi(id[1])
+		if err != nil {
+			return nil, errors.New("couldn't get index of section")
+		}
+		var info information.Information
+		found := false
+		for _, collection := range collections {
+			store, ok := kn.db[collection]
+			if !ok {
+				return nil, fmt.Errorf("collection %s not found", collection)
 			}
-			found := false
-			for _, collection := range collections {
-				id = strings.Split(kn.faissId[indx], ":")
-				if len(id) != 2 {
-					return nil, errors.New("document id in faiss index has wrong format")
-				}
-				sectIndex, err = strconv.Atoi(id[1])
-				if err != nil {
-					return nil, errors.New("couldn't get index of section")
-
-				}
-				count, err := kn.db[collection].Count(&info, bolthold.Where("Hash").Eq(id[0]))
+			count, err := store.Count(&info, bolthold.Where("Hash").Eq(id[0]))
+			if err != nil {
+				return nil, err
+			}
+			if count != 0 {
+				err = store.FindOne(&info, bolthold.Where("Hash").Eq(id[0]))
 				if err != nil {
 					return nil, err
 				}
-				if count != 0 {
-					err = kn.db[collection].FindOne(&info, bolthold.Where("Hash").Eq(id[0]))
-					if err != nil {
-						return nil, err
-					}
-					found = true
-					break
-				}
-			}
-			if !found {
-				return nil, nil
+				found = true
+				break
 			}
-			ret := information.RetSection{
-				Section: info.Sections[sectIndex],
-				Dist:    lengthVec[i],
-				Hash:    info.Hash,
-			}
-			log.Debugf("Doc title: %s", ret.Title)
-			documents = append(documents, ret)
 		}
+		// the index covers all collections, skip the ones not asked for
+		if !found || sectIndex >= len(info.Sections) {
+			continue
+		}
+		ret := information.RetSection{
+			Section: info.Sections[sectIndex],
+			Dist:    lengthVec[i],
+			Hash:    info.Hash,
+			Index:   sectIndex,
+			Source:  info.Source,
+		}
+		log.Debugf("Doc title: %s", ret.Title)
+		documents = append(documents, ret)
 	}
-	return
+	return SelectMMR(emb.Embeddings[0], documents, int(nrDocs), MMRLambda, MaxPerDocument), nil
 }
 
 var letterRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
@@ -169,6 +201,9 @@ func GetEmbedding(collections []string) (embedding string, err error) {
 pass DropCollection function
 */
 func (kn *Knowledge) DropCollection(collection string) error {
+	kn.mutex.Lock()
+	defer kn.mutex.Unlock()
+	kn.resetIndex()
 	if _, ok := kn.db[collection]; ok {
 		delete(kn.db, collection)
 		return nil
@@ -2,12 +2,15 @@ package database
 
 import (
 	"bytes"
+	"fmt"
 	"os"
+	"strings"
 	"text/template"
 
 	"github.com/Masterminds/sprig/v3"
 	"github.com/charmbracelet/log"
 	"github.com/openSUSE/kowalski/internal/pkg/file"
+	"github.com/openSUSE/kowalski/internal/pkg/information"
 	"github.com/openSUSE/kowalski/internal/pkg/templates"
 	"github.com/spf13/viper"
 )
@@ -17,56 +20,258 @@ type PromptInfo struct {
 	Version string
 	Task    string
 	Context string
+	History []string
+	Input   string
 }
 
-func (kn Knowledge) GetContext(msg string, collections []string, location file.Location, maxSize int) (ret string, err error) {
+// fraction of the context size of the modell which can be filled with
+// the prompt and the retrieved documents
+var ContextFraction float64 = 0.75
+
+// tokens which are kept free for the answer of the modell
+var AnswerReserve int = 1024
+
+// number of sections which are retrieved from the index before packing
+var ContextCandidates int64 = 20
+
+// context size used if the modell doesn't report one
+const defaultContextSize = 2048
+
+// document which was used for the context of a prompt
+type Source struct {
+	Title  string  `json:"title" yaml:"title"`
+	Source string  `json:"source" yaml:"source"`
+	Hash   string  `json:"hash" yaml:"hash"`
+	Index  int     `json:"index" yaml:"index"`
+	Dist   float32 `json:"distance" yaml:"distance"`
+}
+
+// the prompt for the LLM and the documents it was created from
+type PromptContext struct {
+	Prompt  string
+	Sources []Source
+}
+
+type ContextOpts struct {
+	fraction      float64
+	answerReserve int
+	history       []Turn
+	queries       []string
+	input         string
+}
+
+type ContextArgs func(*ContextOpts)
+
+// use given fraction of the context size instead of ContextFraction
+func OptionWithFraction(fraction float64) ContextArgs {
+	return func(opts *ContextOpts) {
+		opts.fraction = fraction
+	}
+}
+
+// keep given number of tokens free for the answer
+func OptionWithAnswerReserve(tokens int) ContextArgs {
+	return func(opts *ContextOpts) {
+		opts.answerReserve = tokens
+	}
+}
+
+// send the chat history along with the prompt
+func OptionWithHistory(history []Turn) ContextArgs {
+	return func(opts *ContextOpts) {
+		opts.history = history
+	}
+}
+
+// retrieve the documents with the given queries instead of the message
+func OptionWithQueries(queries []string) ContextArgs {
+	return func(opts *ContextOpts) {
+		opts.queries = queries
+	}
+}
+
+// searches the sections for a context, the local database or a remote server
+type Retriever interface {
+	GetInfos(msg string, collections []string, nrDocs int64) ([]information.RetSection, error)
+}
+
+// additional input of the user, like a log, which is added to the prompt
+func OptionWithInput(input string) ContextArgs {
+	return func(opts *ContextOpts) {
+		opts.input = input
+	}
+}
+
+func (kn *Knowledge) GetContext(msg string, collections []string, location file.Location, maxSize int, args ...ContextArgs) (ret PromptContext, err error) {
 	if len(collections) == 0 {
 		collections = kn.ListCollections()
 	}
+	return BuildContext(kn, msg, collections, location, maxSize, args...)
+}
+
+/*
+Create the prompt for msg with the sections found by the retriever. The
+files referenced by the sections are read from location, so that the
+prompt reflects the system even if the retriever is remote.
+*/
+func BuildContext(retriever Retriever, msg string, collections []string, location file.Location, maxSize int, args ...ContextArgs) (ret PromptContext, err error) {
+	opts := ContextOpts{
+		fraction:      ContextFraction,
+		answerReserve: AnswerReserve,
+	}
+	for _, arg := range args {
+		arg(&opts)
+	}
+	if maxSize <= 0 {
+		log.Warnf("unknown context size, using %d", defaultContextSize)
+		maxSize = defaultContextSize
+	}
 	log.Debugf("creating context(%d) for '%s' in '%s'\n", maxSize, msg, collections)
 	promptInfo := GetSystemInfo()
 	promptInfo.Task = msg
+	promptInfo.History = FormatHistory(opts.history)
+	promptInfo.Input = truncateInput(opts.input, maxSize)
 	funcMap := sprig.FuncMap()
 	var buf bytes.Buffer
-	sysinfo, err := template.New("sysinfo").Funcs(funcMap).Parse(templates.Prompt)
+	sysinfo, err := template.New("sysinfo").Funcs(funcMap).Parse(templates.Get(templates.PromptName))
 	if err != nil {
-		return "", err
+		return ret, err
 	}
 	if err = sysinfo.Execute(&buf, promptInfo); err != nil {
-		return "", err
+		return ret, err
+	}
+	budget := contextBudget(maxSize, opts.fraction, opts.answerReserve, EstimateTokens(buf.String()))
+	queries := opts.queries
+	if len(queries) == 0 {
+		queries = []string{msg}
 	}
-	// \TODO just get 5 documents, we can do this dynamically
-	infos, err := kn.GetInfos(msg, collections, 5)
+	var results [][]information.RetSection
+	for _, query := range queries {
+		queryInfos, err := retriever.GetInfos(query, collections, ContextCandidates)
+		if err != nil {
+			return ret, err
+		}
+		results = append(results, queryInfos)
+	}
+	renderedCont, included, err := PackContext(interleave(results), location, budget)
 	if err != nil {
-		return "", err
+		return ret, err
+	}
+	buf.Reset()
+	promptInfo.Context = renderedCont
+	if err = sysinfo.Execute(&buf, promptInfo); err != nil {
+		return ret, err
+	}
+	ret.Prompt = buf.String()
+	for _, info := range included {
+		ret.Sources = append(ret.Sources, Source{
+			Title:  info.Title,
+			Source: info.Source,
+			Hash:   info.Hash,
+			Index:  info.Index,
+			Dist:   info.Dist,
+		})
+	}
+	return ret, nil
+}
+
+/*
+Keep the end of the input, which is the interesting part of logs, if it
+would fill more than a quarter of the context.
+*/
+func truncateInput(input string, maxSize int) string {
+	input = strings.TrimSpace(input)
+	maxTokens := maxSize / 4
+	if EstimateTokens(input) <= maxTokens {
+		return input
+	}
+	lines := strings.Split(input, "\n")
+	start, used := len(lines), 0
+	for start > 0 && used+EstimateTokens(lines[start-1]) <= maxTokens {
+		start--
+		used += EstimateTokens(lines[start])
 	}
-	contextSize := buf.Len()
-	renderedCont := ""
+	log.Warnf("input is too long, only the last %d of %d lines are used", len(lines)-start, len(lines))
+	return strings.Join(lines[start:], "\n")
+}
+
+/*
+Merge the results of several queries by taking the best section of every
+query, then the second best and so on. So the order of the maximal
+marginal relevance selection is kept, duplicates are removed when packing.
+*/
+func interleave(results [][]information.RetSection) (merged []information.RetSection) {
+	for rank := 0; ; rank++ {
+		added := false
+		for _, infos := range results {
+			if rank < len(infos) {
+				merged = append(merged, infos[rank])
+				added = true
+			}
+		}
+		if !added {
+			return
+		}
+	}
+}
+
+/*
+Tokens left for the documents. The fraction and the reserve for the answer
+are both taken from the context size, whichever leaves less.
+*/
+func contextBudget(maxSize int, fraction float64, answerReserve, prompt int) int {
+	budget := min(int(fraction*float64(maxSize)), maxSize-answerReserve) - prompt
+	return max(budget, 0)
+}
+
+/*
+Render the sections, which must be sorted by their rank, into the context
+as long as the estimated tokens fit into budget. Sections which were
+already added, or have the same content, are skipped. The included
+sections are returned as well.
+*/
+func PackContext(infos []information.RetSection, location file.Location, budget int) (ret string, packed []information.RetSection, err error) {
+	seenIds := make(map[string]bool)
+	seenContent := make(map[string]bool)
+	used := 0
+	var included, dropped []string
 	for _, info := range infos {
-		renderedCont += "This help document may be related to the problem:\n"
-		if str, err := info.Section.Render(map[string]func(string) string{
+		id := fmt.Sprintf("%s:%d", info.Hash, info.Index)
+		if seenIds[id] {
+			dropped = append(dropped, fmt.Sprintf("%s (duplicate)", id))
+			continue
+		}
+		seenIds[id] = true
+		str, err := info.Section.Render(map[string]func(string) string{
 			"FileInfo": func(input string) string { return location.Get(input) },
-		}); err == nil {
-			renderedCont += str
-		} else {
-			return renderedCont, err
+		})
+		if err != nil {
+			return ret, packed, err
 		}
-		// check for renderedCont window
-		if len(renderedCont)+4*contextSize > maxSize {
-			log.Infof("stopped generating context %d > %d", len(renderedCont)+4*contextSize, maxSize)
-			break
+		if seenContent[str] {
+			dropped = append(dropped, fmt.Sprintf("%s (same content)", id))
+			continue
 		}
+		seenContent[str] = true
+		str = "This help document may be related to the problem:\n" + str
+		tokens := EstimateTokens(str)
+		if used+tokens > budget {
+			dropped = append(dropped, fmt.Sprintf("%s '%s' (%d tokens)", id, info.Title, tokens))
+			continue
+		}
+		used += tokens
+		ret += str
+		packed = append(packed, info)
+		included = append(included, fmt.Sprintf("%s '%s' (%d tokens, dist %.3f)", id, info.Title, tokens, info.Dist))
 	}
-	buf.Reset()
-	sysinfo, err = sysinfo.Parse(templates.Prompt)
-	if err != nil {
-		return "", err
+	log.Debugf("packed %d/%d tokens into context", used, budget)
+	for _, inc := range included {
+		log.Debugf("included: %s", inc)
 	}
-	promptInfo.Context = renderedCont
-	if err = sysinfo.Execute(&buf, promptInfo); err != nil {
-		return "", err
+	for _, drop := range dropped {
+		log.Debugf("dropped: %s", drop)
 	}
-	return buf.String(), nil
+	return
 }
 
 func GetSystemInfo() (sysinfo PromptInfo) {
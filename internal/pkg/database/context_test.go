@@ -0,0 +1,122 @@
+package database
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/openSUSE/kowalski/internal/pkg/file"
+	"github.com/openSUSE/kowalski/internal/pkg/information"
+)
+
+func section(hash string, index int, title, text string) information.RetSection {
+	return information.RetSection{
+		Hash:  hash,
+		Index: index,
+		Section: information.Section{
+			Title: title,
+			Lines: []information.Line{{Text: text, Type: information.Text}},
+		},
+	}
+}
+
+func TestPackContext(t *testing.T) {
+	loc := file.Mock{}
+	small := section("a", 0, "Small", "short text")
+	large := section("b", 0, "Large", strings.Repeat("a long line of text ", 50))
+	other := section("c", 0, "Other", "another short text")
+	sameContent := section("d", 3, "Small", "short text")
+	// tokens of every section as packed alone
+	cost := func(sec information.RetSection) int {
+		str, _, err := PackContext([]information.RetSection{sec}, loc, 1<<30)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return EstimateTokens(str)
+	}
+	tests := []struct {
+		name   string
+		infos  []information.RetSection
+		budget int
+		kept   []string
+	}{
+		{"everything fits", []information.RetSection{small, large, other}, 1 << 30,
+			[]string{"Small", "Large", "Other"}},
+		{"nothing fits", []information.RetSection{small, large, other}, 0, nil},
+		{"smaller ones after a too large one are kept", []information.RetSection{small, large, other},
+			cost(small) + cost(other), []string{"Small", "Other"}},
+		{"budget is exact", []information.RetSection{large}, cost(large), []string{"Large"}},
+		{"budget is one short", []information.RetSection{large}, cost(large) - 1, nil},
+		{"duplicates are dropped", []information.RetSection{small, small, other}, 1 << 30,
+			[]string{"Small", "Other"}},
+		{"same content is dropped", []information.RetSection{small, sameContent}, 1 << 30,
+			[]string{"Small"}},
+	}
+	for _, test := range tests {
+		ret, packed, err := PackContext(test.infos, loc, test.budget)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		var kept []string
+		for _, sec := range packed {
+			kept = append(kept, sec.Title)
+			if !strings.Contains(ret, sec.Title) {
+				t.Errorf("%s: %s isn't in the context", test.name, sec.Title)
+			}
+		}
+		if !slices.Equal(kept, test.kept) {
+			t.Errorf("%s: kept %v, want %v", test.name, kept, test.kept)
+		}
+		if used := EstimateTokens(ret); used > test.budget {
+			t.Errorf("%s: %d tokens exceed the budget of %d", test.name, used, test.budget)
+		}
+	}
+}
+
+func TestContextBudget(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxSize  int
+		fraction float64
+		reserve  int
+		prompt   int
+		budget   int
+	}{
+		{"fraction leaves less", 8192, 0.75, 1024, 200, 6144 - 200},
+		{"reserve leaves less", 2048, 0.75, 1024, 200, 1024 - 200},
+		{"no reserve", 2048, 0.5, 0, 24, 1000},
+		{"prompt too long", 2048, 0.75, 1024, 1500, 0},
+		{"reserve larger than the context", 1024, 0.75, 2048, 0, 0},
+	}
+	for _, test := range tests {
+		if budget := contextBudget(test.maxSize, test.fraction, test.reserve, test.prompt); budget != test.budget {
+			t.Errorf("%s: budget %d, want %d", test.name, budget, test.budget)
+		}
+	}
+}
+
+func TestInterleave(t *testing.T) {
+	titles := func(secs []information.RetSection) (ret []string) {
+		for _, sec := range secs {
+			ret = append(ret, sec.Title)
+		}
+		return
+	}
+	a1, a2, a3 := section("a", 1, "a1", ""), section("a", 2, "a2", ""), section("a", 3, "a3", "")
+	b1, b2 := section("b", 1, "b1", ""), section("b", 2, "b2", "")
+	tests := []struct {
+		name    string
+		results [][]information.RetSection
+		merged  []string
+	}{
+		{"single query keeps its order", [][]information.RetSection{{a3, a1, a2}}, []string{"a3", "a1", "a2"}},
+		{"ranks alternate", [][]information.RetSection{{a1, a2, a3}, {b2, b1}}, []string{"a1", "b2", "a2", "b1", "a3"}},
+		{"empty query", [][]information.RetSection{{}, {b1}}, []string{"b1"}},
+		{"no queries", nil, nil},
+	}
+	for _, test := range tests {
+		if merged := titles(interleave(test.results)); !slices.Equal(merged, test.merged) {
+			t.Errorf("%s: merged %v, want %v", test.name, merged, test.merged)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path"
 	"strings"
+	"sync"
 
 	"github.com/DataIntelligenceCrew/go-faiss"
 	"github.com/charmbracelet/log"
@@ -16,9 +17,15 @@ import (
 	"go.etcd.io/bbolt"
 )
 
-const dbSuffix = ".md"
+// suffix of the bolt files of the collections
+const DBSuffix = ".md"
 
+/*
+Knowledge can be shared between goroutines, the mutex guards the map of
+the stores and the index.
+*/
 type Knowledge struct {
+	mutex      sync.RWMutex
 	db         map[string]*bolthold.Store
 	faissIndex *faiss.IndexFlat
 	faissId    []string
@@ -49,11 +56,11 @@ func New(args ...KnowledgeArgs) (*Knowledge, error) {
 		arg(&dbopts)
 	}
 
-	dbBackends, err := fs.Glob(os.DirFS(dbopts.dbPath), "*"+dbSuffix)
+	dbBackends, err := fs.Glob(os.DirFS(dbopts.dbPath), "*"+DBSuffix)
 	if err != nil {
 		return nil, err
 	}
-	kn := Knowledge{
+	kn := &Knowledge{
 		db:     make(map[string]*bolthold.Store),
 		dbPath: dbopts.dbPath,
 	}
@@ -74,14 +81,17 @@ func New(args ...KnowledgeArgs) (*Knowledge, error) {
 		if err != nil {
 			return nil, err
 		}
-		dbName := strings.TrimSuffix(dbFilename, dbSuffix)
+		dbName := strings.TrimSuffix(dbFilename, DBSuffix)
 		log.Debugf("opened db: %s file: %s ro: %v", dbName, dbFilename, kn.boltOpts.ReadOnly)
 		kn.db[dbName] = store
 	}
-	return &kn, nil
+	return kn, nil
 }
 
 func (kn *Knowledge) Close() {
+	kn.mutex.Lock()
+	defer kn.mutex.Unlock()
+	kn.resetIndex()
 	for _, dbName := range kn.db {
 		dbName.Close()
 	}
@@ -97,63 +107,77 @@ func (kn *Knowledge) Path() string {
 	return kn.dbPath
 }
 
+// create the faiss index over all collections, if it doesn't exist yet
 func (kn *Knowledge) CreateIndex() (err error) {
-	if kn.faissIndex == nil {
-		collections := kn.ListCollections()
-		embedding, err := GetEmbedding(collections)
-		if err != nil {
-			return err
-		}
-		embeddingDim := ollamaconnector.Ollamasettings.GetEmbeddingDimension(embedding)
-		if embeddingDim <= 0 {
-			return errors.New("invalid embedding dimension. Is ollama running?")
-		}
-		kn.faissIndex, err = faiss.NewIndexFlat(embeddingDim, 1)
-		if err != nil {
-			return err
-		}
+	kn.mutex.Lock()
+	defer kn.mutex.Unlock()
+	return kn.createIndex()
+}
 
+// must be called with the lock held
+func (kn *Knowledge) createIndex() (err error) {
+	if kn.faissIndex != nil {
+		return nil
+	}
+	collections := kn.listCollections()
+	embedding, err := GetEmbedding(collections)
+	if err != nil {
+		return err
 	}
+	embeddingDim := ollamaconnector.Ollamasettings.GetEmbeddingDimension(embedding)
+	if embeddingDim <= 0 {
+		return errors.New("invalid embedding dimension. Is ollama running?")
+	}
+	index, err := faiss.NewIndexFlat(embeddingDim, 1)
+	if err != nil {
+		return err
+	}
+	var faissId []string
 	for collectionKey, collection := range kn.db {
-		collection.ForEach(&bolthold.Query{}, func(info *information.Information) error {
+		err = collection.ForEach(&bolthold.Query{}, func(info *information.Information) error {
 			for i, sec := range info.Sections {
-				// will have to convert from float64 to float32
-				/*
-					emb := make([]float32, ollamaconnector.Ollamasettings.GetEmbeddingSize())
-					if len(sec.EmbeddingVec) != len(emb) {
-						panic(fmt.Sprintf("wrong embedding dimensions faiss: %d emb: %d", len(sec.EmbeddingVec), len(emb)))
-					}
-					for j := range sec.EmbeddingVec {
-						emb[j] = float32(sec.EmbeddingVec[j])
-					}
-				*/
-				if len(sec.EmbeddingVec) == 0 {
+				if len(sec.EmbeddingVec) != embeddingDim {
 					log.Debugf("couldn't add %s %d\n", sec.Title, len(sec.EmbeddingVec))
 					continue
 				}
-				err := kn.faissIndex.Add(sec.EmbeddingVec)
-				if err != nil {
-					panic("failed to add document to faiss index")
+				if err := index.Add(sec.EmbeddingVec); err != nil {
+					return fmt.Errorf("failed to add document to faiss index: %w", err)
 				}
 				index := i
 				if sec.IsAlias {
 					index = 0
 				}
-				kn.faissId = append(kn.faissId, info.Hash+fmt.Sprintf(":%d", index))
+				faissId = append(faissId, info.Hash+fmt.Sprintf(":%d", index))
 			}
 			return nil
 		})
+		if err != nil {
+			index.Delete()
+			return err
+		}
 		log.Debugf("indexed: %s", collectionKey)
 	}
-	// \TODO close db
-	// kn.db.Close()
-
+	kn.faissIndex = index
+	kn.faissId = faissId
 	return
 }
 
+// drop the index, so that it's recreated with the next search. Must be
+// called with the lock held
+func (kn *Knowledge) resetIndex() {
+	if kn.faissIndex != nil {
+		kn.faissIndex.Delete()
+	}
+	kn.faissIndex = nil
+	kn.faissId = nil
+}
+
 // drop the information from the database. As well the clover document id is matched
 // as the hash of the file which was used to add the documentation
 func (kn *Knowledge) DropInformation(docId string) (err error) {
+	kn.mutex.Lock()
+	defer kn.mutex.Unlock()
+	kn.resetIndex()
 	for _, coll := range kn.db {
 		count, err := coll.Count(&information.Information{}, bolthold.Where("Hash").Eq(docId))
 		if err != nil {
@@ -9,14 +9,16 @@ import (
 )
 
 type DocumentInfo struct {
-	Id         string
-	Source     string
-	NrFiles    int
-	NrCommands int
+	Id         string `json:"id"`
+	Source     string `json:"source"`
+	NrFiles    int    `json:"files"`
+	NrCommands int    `json:"commands"`
 }
 
 // return all documents of given collection
 func (kn *Knowledge) List(collection string) (docLst []DocumentInfo, err error) {
+	kn.mutex.RLock()
+	defer kn.mutex.RUnlock()
 	if collStor, ok := kn.db[collection]; ok {
 		collStor.ForEach(&bolthold.Query{}, func(info *information.Information) error {
 			docLst = append(docLst, DocumentInfo{
@@ -36,6 +38,8 @@ func (kn *Knowledge) List(collection string) (docLst []DocumentInfo, err error)
 func (kn *Knowledge) Get(id string) (information.Information, error) {
 	var info information.Information
 	found := false
+	kn.mutex.RLock()
+	defer kn.mutex.RUnlock()
 	for collName, coll := range kn.db {
 		err := coll.Get(id, &info)
 		if err == nil {
@@ -50,12 +54,49 @@ func (kn *Knowledge) Get(id string) (information.Information, error) {
 	return info, nil
 }
 
+// return the section with index of the document with the given hash
+func (kn *Knowledge) GetSection(id string, index int) (sec information.Section, err error) {
+	info, err := kn.Get(id)
+	if err != nil {
+		return sec, err
+	}
+	if index < 0 || index >= len(info.Sections) {
+		return sec, fmt.Errorf("document %s has no section %d", id, index)
+	}
+	return info.Sections[index], nil
+}
+
 /*
 return a list of all colletions in the database
 */
 func (kn *Knowledge) ListCollections() (collections []string) {
+	kn.mutex.RLock()
+	defer kn.mutex.RUnlock()
+	return kn.listCollections()
+}
+
+// must be called with the lock held
+func (kn *Knowledge) listCollections() (collections []string) {
 	for key := range kn.db {
 		collections = append(collections, key)
 	}
 	return collections
 }
+
+// count the sections of a collection by the length of their embedding vector
+func (kn *Knowledge) VectorDimensions(collection string) (dims map[int]int, err error) {
+	kn.mutex.RLock()
+	defer kn.mutex.RUnlock()
+	store, ok := kn.db[collection]
+	if !ok {
+		return nil, fmt.Errorf("collection doesn't exist: %s", collection)
+	}
+	dims = make(map[int]int)
+	err = store.ForEach(&bolthold.Query{}, func(info *information.Information) error {
+		for _, sec := range info.Sections {
+			dims[len(sec.EmbeddingVec)]++
+		}
+		return nil
+	})
+	return dims, err
+}
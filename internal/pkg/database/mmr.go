@@ -0,0 +1,81 @@
+package database
+
+import (
+	"math"
+
+	"github.com/charmbracelet/log"
+	"github.com/openSUSE/kowalski/internal/pkg/information"
+)
+
+/*
+Trade off between relevance and diversity for the maximal marginal
+relevance selection. 1 selects by relevance only, 0 by diversity only.
+*/
+var MMRLambda float64 = 0.7
+
+// maximal number of sections from the same document, 0 means unlimited
+var MaxPerDocument int = 0
+
+// factor for the candidates fetched from the index before the selection
+const mmrFetchFactor = 4
+
+/*
+Select up to nrDocs sections out of the candidates with maximal marginal
+relevance, so that sections which are near identical to already selected
+ones are ranked down. Sections without embedding are only compared by
+their distance.
+*/
+func SelectMMR(query []float32, candidates []information.RetSection, nrDocs int, lambda float64, maxPerDoc int) (selected []information.RetSection) {
+	relevance := make([]float64, len(candidates))
+	for i, cand := range candidates {
+		relevance[i] = cosine(query, cand.EmbeddingVec)
+	}
+	used := make([]bool, len(candidates))
+	perDoc := make(map[string]int)
+	for len(selected) < nrDocs {
+		best := -1
+		bestScore := math.Inf(-1)
+		for i, cand := range candidates {
+			if used[i] {
+				continue
+			}
+			if maxPerDoc > 0 && perDoc[cand.Hash] >= maxPerDoc {
+				continue
+			}
+			redundancy := 0.0
+			for _, sel := range selected {
+				redundancy = max(redundancy, cosine(cand.EmbeddingVec, sel.EmbeddingVec))
+			}
+			score := lambda*relevance[i] - (1-lambda)*redundancy
+			if score > bestScore {
+				best = i
+				bestScore = score
+			}
+		}
+		if best < 0 {
+			break
+		}
+		used[best] = true
+		perDoc[candidates[best].Hash]++
+		selected = append(selected, candidates[best])
+		log.Debugf("mmr selected '%s' score: %.3f", candidates[best].Title, bestScore)
+	}
+	return
+}
+
+// cosine similarity of the vectors, 0 if the dimensions don't match
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/openSUSE/kowalski/internal/pkg/information"
+)
+
+func candidate(hash, title string, vec ...float32) information.RetSection {
+	return information.RetSection{
+		Hash:    hash,
+		Section: information.Section{Title: title, EmbeddingVec: vec},
+	}
+}
+
+func TestSelectMMR(t *testing.T) {
+	query := []float32{1, 0, 0}
+	// a and b are near identical, c is less relevant but different
+	candidates := []information.RetSection{
+		candidate("doc1", "a", 0.9, 0.1, 0),
+		candidate("doc1", "b", 0.9, 0.12, 0),
+		candidate("doc2", "c", 0.7, 0, 0.7),
+	}
+	tests := []struct {
+		name      string
+		nrDocs    int
+		lambda    float64
+		maxPerDoc int
+		selected  []string
+	}{
+		{"relevance only", 3, 1, 0, []string{"a", "b", "c"}},
+		{"mostly relevance", 3, 0.7, 0, []string{"a", "b", "c"}},
+		{"balanced", 3, 0.5, 0, []string{"a", "c", "b"}},
+		{"mostly diversity", 3, 0.3, 0, []string{"a", "c", "b"}},
+		{"limited number", 2, 0.5, 0, []string{"a", "c"}},
+		{"one per document", 3, 1, 1, []string{"a", "c"}},
+		{"more wanted than there are", 5, 1, 0, []string{"a", "b", "c"}},
+	}
+	for _, test := range tests {
+		var selected []string
+		for _, sec := range SelectMMR(query, candidates, test.nrDocs, test.lambda, test.maxPerDoc) {
+			selected = append(selected, sec.Title)
+		}
+		if !slices.Equal(selected, test.selected) {
+			t.Errorf("%s: selected %v, want %v", test.name, selected, test.selected)
+		}
+	}
+}
+
+func TestCosine(t *testing.T) {
+	tests := []struct {
+		a, b []float32
+		cos  float64
+	}{
+		{[]float32{1, 0}, []float32{2, 0}, 1},
+		{[]float32{1, 0}, []float32{0, 1}, 0},
+		{[]float32{1, 0}, []float32{-1, 0}, -1},
+		// dimensions don't match or no vector
+		{[]float32{1, 0}, []float32{1, 0, 0}, 0},
+		{nil, nil, 0},
+		{[]float32{0, 0}, []float32{1, 0}, 0},
+	}
+	for _, test := range tests {
+		if cos := cosine(test.a, test.b); cos != test.cos {
+			t.Errorf("cosine(%v, %v) = %f, want %f", test.a, test.b, cos, test.cos)
+		}
+	}
+}
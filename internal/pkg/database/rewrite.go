@@ -0,0 +1,91 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/charmbracelet/log"
+	"github.com/openSUSE/kowalski/internal/app/ollamaconnector"
+	"github.com/openSUSE/kowalski/internal/pkg/templates"
+)
+
+// maximal number of search queries a question is split into
+const maxSubQueries = 3
+
+// question and answer of a previous chat turn
+type Turn struct {
+	Question string `json:"question" yaml:"question"`
+	Answer   string `json:"answer" yaml:"answer"`
+	// the question are the results of the proposed commands
+	Results bool `json:"results,omitempty" yaml:"results,omitempty"`
+}
+
+// render the turns as they are presented to the LLM
+func FormatHistory(history []Turn) (ret []string) {
+	for _, turn := range history {
+		sender := "User: "
+		if turn.Results {
+			sender = "Command results: "
+		}
+		ret = append(ret, sender+turn.Question+"\nKowalski: "+turn.Answer)
+	}
+	return
+}
+
+// strip enumerations and quotes the LLM likes to add
+var queryClean = regexp.MustCompile(`^\s*(?:[-*]|\d+[.)])?\s*["']?(.*?)["']?\s*$`)
+
+// data of the rewrite-query template
+type RewriteInfo struct {
+	History []string
+	Task    string
+	Multi   bool
+}
+
+/*
+Let the LLM rewrite the question with the help of the chat history into
+standalone search queries, so that follow up questions can be retrieved.
+If multi is set, the question may be split in up to three queries.
+Without history the question is returned as is.
+*/
+func RewriteQuery(ctx context.Context, llm ollamaconnector.Generator, history []Turn, question string, multi bool) (queries []string, err error) {
+	if len(history) == 0 {
+		return []string{question}, nil
+	}
+	tmpl, err := template.New("rewrite").Parse(templates.Get(templates.RewriteQueryName))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, RewriteInfo{
+		History: FormatHistory(history),
+		Task:    question,
+		Multi:   multi,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := llm.SendTask(ctx, buf.String())
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(resp.Response, "\n") {
+		query := queryClean.FindStringSubmatch(line)[1]
+		if query == "" {
+			continue
+		}
+		queries = append(queries, query)
+		if !multi || len(queries) == maxSubQueries {
+			break
+		}
+	}
+	if len(queries) == 0 {
+		return nil, errors.New("LLM returned no search query")
+	}
+	log.Debugf("rewrote '%s' to %q", question, queries)
+	return queries, nil
+}
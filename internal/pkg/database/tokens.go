@@ -0,0 +1,17 @@
+package database
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// ollama doesn't offer a tokenizer, so we use the common estimate of
+// four characters per token, but count at least one token per word
+const charsPerToken = 4
+
+// estimate the number of tokens the LLM needs for str
+func EstimateTokens(str string) int {
+	chars := (utf8.RuneCountInString(str) + charsPerToken - 1) / charsPerToken
+	words := len(strings.Fields(str))
+	return max(chars, words)
+}
@@ -0,0 +1,23 @@
+package database
+
+import "testing"
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		str    string
+		tokens int
+	}{
+		{"", 0},
+		{"abcd", 1},
+		{"abcde", 2},
+		// a word is at least one token
+		{"a b c d e f", 6},
+		// runes, not bytes are counted
+		{"äöüß", 1},
+	}
+	for _, test := range tests {
+		if tokens := EstimateTokens(test.str); tokens != test.tokens {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", test.str, tokens, test.tokens)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package evaluate
 
+import "github.com/openSUSE/kowalski/internal/app/ollamaconnector"
+
 type Evaluation struct {
 	// name of evluation, must be provided
 	Name   string `yaml:"name"`
@@ -18,6 +20,8 @@ type EvlatuationResult struct {
 	PromptEvalDuration int      `yaml:"prompt_eval_duration"`
 	EvalCount          int      `yaml:"eval_count"`
 	EvalDuration       int64    `yaml:"eval_duration"`
+	// modell and options the response was generated with
+	Generation ollamaconnector.Generation `yaml:"generation"`
 	Evaluation
 }
 
@@ -28,9 +32,12 @@ type File struct {
 
 type EvalutaionList struct {
 	// uuid of evaluation after run
-	Id          string        `yaml:"id,omitempty"`
-	Version     string        `yaml:"version,omitempty"`
-	LLM         string        `yaml:"llm,omitempty"`
-	Embedding   string        `yaml:"embedding,omitempty"`
-	Evaluations []*Evaluation `yaml:"evaluations"`
+	Id        string  `yaml:"id,omitempty"`
+	Version   string  `yaml:"version,omitempty"`
+	LLM       string  `yaml:"llm,omitempty"`
+	Embedding string  `yaml:"embedding,omitempty"`
+	Lambda    float64 `yaml:"lambda,omitempty"`
+	// options of the modell, so that the run can be repeated
+	Generation  ollamaconnector.Generation `yaml:"generation"`
+	Evaluations []*Evaluation              `yaml:"evaluations"`
 }
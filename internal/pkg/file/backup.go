@@ -0,0 +1,204 @@
+package file
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/openSUSE/kowalski/internal/pkg/xdg"
+)
+
+// directory in which the backups of changed files are stored
+var BackupDir = xdg.StateHome("backups")
+
+const backupManifest = "manifest.json"
+
+// format of the backup id, so that they sort by time
+const backupIdFormat = "20060102-150405.000"
+
+// original state of a changed file
+type BackupEntry struct {
+	Path    string      `json:"path"`
+	Chroot  string      `json:"chroot,omitempty"`
+	Existed bool        `json:"existed"`
+	Mode    fs.FileMode `json:"mode"`
+}
+
+type Backup struct {
+	Id      string        `json:"id"`
+	Time    time.Time     `json:"time"`
+	Entries []BackupEntry `json:"entries"`
+}
+
+/*
+Write the content atomically to path under the chroot. The original file
+is backed up before, the id of the backup is returned, so that the change
+can be reverted with Undo.
+*/
+func (loc Local) Write(path string, content string) (backupId string, err error) {
+	fullPath := loc.fullPath(path)
+	entry := BackupEntry{
+		// the same path which is written, so that undo restores it
+		Path:   filepath.Clean("/" + path),
+		Chroot: loc.Chroot,
+		Mode:   0644,
+	}
+	stat, err := os.Stat(fullPath)
+	switch {
+	case err == nil:
+		if stat.IsDir() {
+			return "", fmt.Errorf("%s is a directory", fullPath)
+		}
+		entry.Existed = true
+		entry.Mode = stat.Mode().Perm()
+	case !errors.Is(err, fs.ErrNotExist):
+		return "", err
+	}
+	now := time.Now()
+	// changes in the same millisecond need their own backup
+	for {
+		if _, err := os.Stat(filepath.Join(BackupDir, now.Format(backupIdFormat))); errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		now = now.Add(time.Millisecond)
+	}
+	backup := Backup{
+		Id:      now.Format(backupIdFormat),
+		Time:    now,
+		Entries: []BackupEntry{entry},
+	}
+	backupPath := filepath.Join(BackupDir, backup.Id)
+	if err = os.MkdirAll(backupPath, 0700); err != nil {
+		return "", err
+	}
+	if entry.Existed {
+		orig, err := os.ReadFile(fullPath)
+		if err != nil {
+			return "", err
+		}
+		if err = atomicWrite(filepath.Join(backupPath, "files", entry.Path), orig, 0600); err != nil {
+			return "", err
+		}
+	}
+	manifest, _ := json.MarshalIndent(backup, "", "  ")
+	if err = os.WriteFile(filepath.Join(backupPath, backupManifest), manifest, 0600); err != nil {
+		return "", err
+	}
+	if err = atomicWrite(fullPath, []byte(content), entry.Mode); err != nil {
+		os.RemoveAll(backupPath)
+		return "", err
+	}
+	log.Debugf("wrote %s, backup: %s", fullPath, backup.Id)
+	return backup.Id, nil
+}
+
+// write to a temporary file in the same directory and rename it
+func atomicWrite(path string, content []byte, mode fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".kowalski-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// list all backups, the newest is the last one
+func ListBackups() (backups []Backup, err error) {
+	dirs, err := os.ReadDir(BackupDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		backup, err := readBackup(dir.Name())
+		if err != nil {
+			log.Warnf("skipping backup %s: %s", dir.Name(), err)
+			continue
+		}
+		backups = append(backups, backup)
+	}
+	slices.SortFunc(backups, func(a, b Backup) int {
+		return strings.Compare(a.Id, b.Id)
+	})
+	return
+}
+
+func readBackup(id string) (backup Backup, err error) {
+	manifest, err := os.ReadFile(filepath.Join(BackupDir, id, backupManifest))
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(manifest, &backup)
+	return
+}
+
+/*
+Restore the files of the backup with the given id, or of the latest backup
+if id is empty. Files which didn't exist before are removed. The backup is
+deleted afterwards.
+*/
+func Undo(id string) (backup Backup, err error) {
+	if id == "" {
+		backups, err := ListBackups()
+		if err != nil {
+			return backup, err
+		}
+		if len(backups) == 0 {
+			return backup, errors.New("no backups found")
+		}
+		id = backups[len(backups)-1].Id
+	}
+	backup, err = readBackup(id)
+	if err != nil {
+		return backup, fmt.Errorf("couldn't read backup %s: %w", id, err)
+	}
+	backupPath := filepath.Join(BackupDir, backup.Id)
+	for _, entry := range backup.Entries {
+		fullPath := filepath.Join(entry.Chroot, entry.Path)
+		if !entry.Existed {
+			if err = os.Remove(fullPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return backup, err
+			}
+			log.Debugf("removed %s", fullPath)
+			continue
+		}
+		orig, err := os.ReadFile(filepath.Join(backupPath, "files", entry.Path))
+		if err != nil {
+			return backup, err
+		}
+		if err = atomicWrite(fullPath, orig, entry.Mode); err != nil {
+			return backup, err
+		}
+		log.Debugf("restored %s", fullPath)
+	}
+	return backup, os.RemoveAll(backupPath)
+}
@@ -0,0 +1,96 @@
+package file
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteStaysInChroot(t *testing.T) {
+	chroot := t.TempDir()
+	BackupDir = t.TempDir()
+	loc := Local{Chroot: chroot}
+	tests := []struct {
+		path string
+		full string
+	}{
+		{"/etc/motd", "etc/motd"},
+		{"sshd_config", "sshd_config"},
+		{"../../etc/shadow", "etc/shadow"},
+		{"/etc/../../passwd", "passwd"},
+	}
+	for _, test := range tests {
+		id, err := loc.Write(test.path, "new\n")
+		if err != nil {
+			t.Fatalf("%s: %s", test.path, err)
+		}
+		full := filepath.Join(chroot, test.full)
+		if content, err := loc.Read(test.path); err != nil || content != "new\n" {
+			t.Errorf("%s: read %q, %v", test.path, content, err)
+		}
+		if _, err := os.Stat(full); err != nil {
+			t.Errorf("%s: not written to %s: %s", test.path, full, err)
+		}
+		// undo removes the same file, as it didn't exist before
+		if _, err := Undo(id); err != nil {
+			t.Fatalf("%s: %s", test.path, err)
+		}
+		if _, err := os.Stat(full); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s: %s wasn't removed by undo", test.path, full)
+		}
+	}
+}
+
+func TestUndoRestores(t *testing.T) {
+	chroot := t.TempDir()
+	BackupDir = t.TempDir()
+	loc := Local{Chroot: chroot}
+	full := filepath.Join(chroot, "etc", "motd")
+	os.MkdirAll(filepath.Dir(full), 0755)
+	if err := os.WriteFile(full, []byte("old\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	id, err := loc.Write("etc/motd", "new\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Undo(id); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(full)
+	if err != nil || string(content) != "old\n" {
+		t.Errorf("restored %q, %v", content, err)
+	}
+	if stat, err := os.Stat(full); err != nil || stat.Mode().Perm() != 0600 {
+		t.Errorf("mode wasn't restored: %v", err)
+	}
+}
+
+func TestGetStaysInChroot(t *testing.T) {
+	chroot := t.TempDir()
+	outside := filepath.Join(filepath.Dir(chroot), "secret")
+	if err := os.WriteFile(outside, []byte("classified\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(outside)
+	os.WriteFile(filepath.Join(chroot, "motd"), []byte("hello\n"), 0644)
+	loc := Local{Chroot: chroot}
+	tests := []struct {
+		path     string
+		contains string
+	}{
+		{"/motd", "hello"},
+		{"motd", "hello"},
+		{"../secret", "doesn't exist"},
+		{"../../" + filepath.Base(filepath.Dir(chroot)) + "/secret", "doesn't exist"},
+	}
+	for _, test := range tests {
+		out := loc.Get(test.path)
+		if strings.Contains(out, "classified") || !strings.Contains(out, test.contains) {
+			t.Errorf("Get(%q) = %q, want %q", test.path, out, test.contains)
+		}
+	}
+}
@@ -19,7 +19,12 @@ const (
 )
 
 type Location interface {
+	// description of the path for the LLM
 	Get(string) string
+	// actual content of the path
+	Read(string) (string, error)
+	// entries of the directory
+	List(string) ([]string, error)
 }
 
 type Local struct {
@@ -30,6 +35,10 @@ type SSH struct {
 	Host string
 }
 
+// location without any files, for when the files of the system
+// must not be exposed
+type Empty struct{}
+
 // use for mocking, content is just a map with the content of path
 type Mock struct {
 	Content map[string]string
@@ -45,7 +54,7 @@ func (loc *Location) Get(path string) string {
 */
 
 func (loc Local) Get(path string) (out string) {
-	path = filepath.Join(loc.Chroot, path)
+	path = loc.fullPath(path)
 	fileStat, err := os.Stat(path)
 	if errors.Is(err, os.ErrNotExist) {
 		if strings.HasSuffix(path, "/") {
@@ -53,6 +62,10 @@ func (loc Local) Get(path string) (out string) {
 		} else {
 			out += fmt.Sprintf("* path %s doesn't exist on the system\n", path)
 		}
+		return out
+	}
+	if err != nil {
+		return fmt.Sprintf("* path %s couldn't be accessed\n", path)
 	}
 	if fileStat.IsDir() {
 		entries, _ := os.ReadDir(path)
@@ -68,10 +81,11 @@ func (loc Local) Get(path string) (out string) {
 		}
 	} else {
 		if fileStat.Size() < filemaxsize {
-			readFile, _ := os.Open(os.Args[1])
+			readFile, err := os.Open(path)
 			if err != nil {
-				out += fmt.Sprintf("* path %s couldn't be opened", path)
+				return out + fmt.Sprintf("* path %s couldn't be opened", path)
 			}
+			defer readFile.Close()
 			fileScanner := bufio.NewScanner(readFile)
 			fileScanner.Split(bufio.ScanLines)
 			fileScanner.Scan()
@@ -96,3 +110,61 @@ func (mock Mock) Get(path string) string {
 		return ""
 	}
 }
+
+// path below the chroot, relative paths and .. can't leave it
+func (loc Local) fullPath(path string) string {
+	return filepath.Join(loc.Chroot, filepath.Clean("/"+path))
+}
+
+func (loc Local) Read(path string) (string, error) {
+	content, err := os.ReadFile(loc.fullPath(path))
+	return string(content), err
+}
+
+func (mock Mock) Read(path string) (string, error) {
+	if val, ok := mock.Content[path]; ok {
+		return val, nil
+	}
+	return "", fmt.Errorf("%s: %w", path, os.ErrNotExist)
+}
+
+func (loc Local) List(path string) (entries []string, err error) {
+	dirEntries, err := os.ReadDir(loc.fullPath(path))
+	if err != nil {
+		return nil, err
+	}
+	for _, ent := range dirEntries {
+		name := ent.Name()
+		if ent.IsDir() {
+			name += "/"
+		}
+		entries = append(entries, name)
+	}
+	return
+}
+
+// entries of the mock are all the paths below path
+func (mock Mock) List(path string) (entries []string, err error) {
+	prefix := strings.TrimSuffix(path, "/") + "/"
+	for key := range mock.Content {
+		if strings.HasPrefix(key, prefix) {
+			entries = append(entries, strings.TrimPrefix(key, prefix))
+		}
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%s: %w", path, os.ErrNotExist)
+	}
+	return
+}
+
+func (Empty) Get(path string) string {
+	return ""
+}
+
+func (Empty) Read(path string) (string, error) {
+	return "", fmt.Errorf("%s: %w", path, os.ErrNotExist)
+}
+
+func (Empty) List(path string) ([]string, error) {
+	return nil, fmt.Errorf("%s: %w", path, os.ErrNotExist)
+}
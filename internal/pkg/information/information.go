@@ -77,14 +77,27 @@ type Section struct {
 
 // data returned from db for the LLM modell
 type RetSection struct {
-	Dist float32
-	Hash string // hash which identifies base doc
+	Dist   float32
+	Hash   string // hash which identifies base doc
+	Index  int    // index of the section in the base doc
+	Source string // source file of the base doc
 	Section
 }
 
-func (info *Section) Render(args ...any) (string, error) {
+/*
+Functions for rendering, FileInfo and RenderFile just return the path
+unless the caller passes its own, so that every template can use them.
+*/
+func renderFuncs() template.FuncMap {
 	funcMap := sprig.FuncMap()
-	tmpl := templates.RenderInfo
+	funcMap["FileInfo"] = func(input string) string { return input }
+	funcMap["RenderFile"] = func(input string) string { return input }
+	return funcMap
+}
+
+func (info *Section) Render(args ...any) (string, error) {
+	funcMap := renderFuncs()
+	tmpl := templates.Get(templates.RenderInfoName)
 	for _, arg := range args {
 		switch t := arg.(type) {
 		case string:
@@ -98,6 +111,7 @@ func (info *Section) Render(args ...any) (string, error) {
 	template, err := template.New("sections").Funcs(funcMap).Parse(tmpl)
 	if err != nil {
 		log.Printf("couldn't parse template: %s\n", err)
+		return "", err
 	}
 	var buf bytes.Buffer
 	if err := template.Execute(&buf, *info); err != nil {
@@ -142,8 +156,8 @@ func (info *Information) CreateEmbedding(embedding string) (err error) {
 }
 
 func (info *Information) Render(args ...any) (ret string, err error) {
-	funcMap := sprig.FuncMap()
-	tmpl := templates.RenderInfo
+	funcMap := renderFuncs()
+	tmpl := templates.Get(templates.RenderInfoName)
 	for _, arg := range args {
 		switch t := arg.(type) {
 		case string:
@@ -157,6 +171,7 @@ func (info *Information) Render(args ...any) (ret string, err error) {
 	template, err := template.New("RenderInformation").Funcs(funcMap).Parse(tmpl)
 	if err != nil {
 		log.Warnf("error %s for template %s: \n", err, tmpl)
+		return "", err
 	}
 	var buf bytes.Buffer
 	if err := template.Execute(&buf, info); err != nil {
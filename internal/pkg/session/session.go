@@ -0,0 +1,242 @@
+/*
+Chat sessions are stored as json files, so that a chat can be resumed or
+handed over to a colleague as transcript.
+*/
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+	"github.com/openSUSE/kowalski/internal/pkg/audit"
+	"github.com/openSUSE/kowalski/internal/pkg/database"
+	"github.com/openSUSE/kowalski/internal/pkg/xdg"
+)
+
+// directory in which the sessions are stored
+var Dir = xdg.StateHome("sessions")
+
+const suffix = ".json"
+
+// id which selects the most recent session
+const Latest = "latest"
+
+// message of the chat as shown in the TUI
+type Message struct {
+	Sender  string            `json:"sender"`
+	Text    string            `json:"text"`
+	Sources []database.Source `json:"sources,omitempty"`
+	// the answer was stopped by the user
+	Interrupted bool `json:"interrupted,omitempty"`
+}
+
+type Session struct {
+	Id       string    `json:"id"`
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+	Model    string    `json:"model,omitempty"`
+	Messages []Message `json:"messages"`
+	// questions and answers which are sent as history to the LLM
+	Turns []database.Turn `json:"turns,omitempty"`
+	// files and commands which were applied, rejected or denied
+	Actions []audit.Entry `json:"actions,omitempty"`
+}
+
+func New(model string) *Session {
+	now := time.Now()
+	return &Session{
+		Id:      uuid.New().String(),
+		Created: now,
+		Updated: now,
+		Model:   model,
+	}
+}
+
+// the first question of the session
+func (sess *Session) Title() string {
+	if len(sess.Turns) > 0 {
+		return sess.Turns[0].Question
+	}
+	return ""
+}
+
+// write the session atomically, as it may contain system details it's
+// only readable by the user
+func (sess *Session) Save() error {
+	if err := os.MkdirAll(Dir, 0700); err != nil {
+		return err
+	}
+	sess.Updated = time.Now()
+	data, err := json.MarshalIndent(sess, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(Dir, "."+sess.Id+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(Dir, sess.Id+suffix))
+}
+
+// all sessions, the most recent first
+func List() (sessions []Session, err error) {
+	entries, err := os.ReadDir(Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), suffix) || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		sess, err := read(filepath.Join(Dir, entry.Name()))
+		if err != nil {
+			log.Warnf("skipping session %s: %s", entry.Name(), err)
+			continue
+		}
+		sessions = append(sessions, *sess)
+	}
+	slices.SortFunc(sessions, func(a, b Session) int {
+		return b.Updated.Compare(a.Updated)
+	})
+	return sessions, nil
+}
+
+func read(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, fmt.Errorf("couldn't read session %s: %w", path, err)
+	}
+	return &sess, nil
+}
+
+/*
+Load the session with the given id, a unique prefix of the id is enough.
+Latest loads the most recent session.
+*/
+func Load(id string) (*Session, error) {
+	sessions, err := List()
+	if err != nil {
+		return nil, err
+	}
+	if id == Latest {
+		if len(sessions) == 0 {
+			return nil, errors.New("no session found")
+		}
+		return &sessions[0], nil
+	}
+	var found []Session
+	for _, sess := range sessions {
+		if sess.Id == id {
+			return &sess, nil
+		}
+		if strings.HasPrefix(sess.Id, id) {
+			found = append(found, sess)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("session not found: %s", id)
+	case 1:
+		return &found[0], nil
+	}
+	return nil, fmt.Errorf("session id %s is ambiguous", id)
+}
+
+func Delete(id string) error {
+	sess, err := Load(id)
+	if err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(Dir, sess.Id+suffix))
+}
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// remove the colors of the TUI
+func StripColor(text string) string {
+	return ansiEscape.ReplaceAllString(text, "")
+}
+
+// transcript of the session, which can be handed over
+func (sess *Session) Markdown() string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "# Kowalski session %s\n\n", sess.Id)
+	fmt.Fprintf(&out, "Started %s", sess.Created.Format(time.DateTime))
+	if sess.Model != "" {
+		fmt.Fprintf(&out, " with model %s", sess.Model)
+	}
+	out.WriteString("\n")
+	for _, msg := range sess.Messages {
+		fmt.Fprintf(&out, "\n**%s**:\n\n", msg.Sender)
+		text := strings.TrimSpace(StripColor(msg.Text))
+		if strings.HasPrefix(msg.Sender, "Review") || msg.Sender == "Tool" {
+			// diffs and command output
+			fmt.Fprintf(&out, "```\n%s\n```\n", text)
+		} else {
+			out.WriteString(text + "\n")
+		}
+		if msg.Interrupted {
+			out.WriteString("\n_(interrupted)_\n")
+		}
+		if len(msg.Sources) > 0 {
+			out.WriteString("\nSources:\n")
+			for i, src := range msg.Sources {
+				fmt.Fprintf(&out, "%d. %s (%s)\n", i+1, src.Title, src.Source)
+			}
+		}
+	}
+	if len(sess.Actions) > 0 {
+		out.WriteString("\n## Actions\n\n")
+		for _, action := range sess.Actions {
+			out.WriteString("- " + FormatAction(action) + "\n")
+		}
+	}
+	return out.String()
+}
+
+// one line description of a file or command action
+func FormatAction(action audit.Entry) string {
+	var what string
+	switch action.Type {
+	case audit.FileEntry:
+		what = "file `" + action.Path + "`"
+	default:
+		what = "command `" + action.Command + "`"
+	}
+	ret := fmt.Sprintf("%s %s: %s", action.Time.Format(time.TimeOnly), action.Status, what)
+	if action.ExitCode != nil {
+		ret += fmt.Sprintf(", exit code %d", *action.ExitCode)
+	}
+	if action.Backup != "" {
+		ret += ", backup " + action.Backup
+	}
+	if action.Error != "" {
+		ret += ", " + action.Error
+	}
+	return ret
+}
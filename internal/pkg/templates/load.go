@@ -0,0 +1,143 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/openSUSE/kowalski/internal/pkg/xdg"
+)
+
+// names of the templates, an override is read from NAME.tmpl
+const (
+	PromptName             = "prompt"
+	AgentPromptName        = "agent-prompt"
+	RewriteQueryName       = "rewrite-query"
+	CommandResultsName     = "command-results"
+	RenderInfoName         = "render-info"
+	RenderInfoWithMetaName = "render-info-with-meta"
+	RenderTitleOnlyName    = "render-title-only"
+)
+
+var builtins = map[string]string{
+	PromptName:             Prompt,
+	AgentPromptName:        AgentPrompt,
+	RewriteQueryName:       RewriteQuery,
+	CommandResultsName:     CommandResults,
+	RenderInfoName:         RenderInfo,
+	RenderInfoWithMetaName: RenderInfoWithMeta,
+	RenderTitleOnlyName:    RenderTitleOnly,
+}
+
+/*
+Directories with the overrides, later ones win. Templates for a single
+modell are read from a subdirectory named like the modell, e.g.
+~/.config/kowalski/templates/gemma3:4b/prompt.tmpl
+*/
+var Dirs = []string{"/etc/kowalski/templates", xdg.ConfigHome("templates")}
+
+// modell whose templates are used
+var Model string
+
+const suffix = ".tmpl"
+
+type override struct {
+	text string
+	path string
+}
+
+// overrides by modell, the empty modell is used for all modells
+var overrides = map[string]map[string]override{}
+
+// names of all templates
+func Names() (names []string) {
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+func Builtin(name string) (text string, ok bool) {
+	text, ok = builtins[name]
+	return
+}
+
+func lookup(name string) (override, bool) {
+	for _, model := range []string{Model, strings.TrimSuffix(Model, ":latest"), ""} {
+		if over, ok := overrides[model][name]; ok {
+			return over, true
+		}
+	}
+	return override{}, false
+}
+
+// the template for the current modell, the built-in one if not overridden
+func Get(name string) string {
+	if over, ok := lookup(name); ok {
+		return over.text
+	}
+	return builtins[name]
+}
+
+// path of the file the template was read from or built-in
+func Origin(name string) string {
+	if over, ok := lookup(name); ok {
+		return over.path
+	}
+	return "built-in"
+}
+
+/*
+Read the overrides from Dirs. Every template is validated with check, the
+built-in one is kept if the file can't be read or fails the check. All
+problems are returned together.
+*/
+func Load(check func(name, text string) error) error {
+	overrides = map[string]map[string]override{}
+	var errs []error
+	for _, dir := range Dirs {
+		errs = append(errs, loadDir(dir, "", check)...)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				errs = append(errs, loadDir(filepath.Join(dir, entry.Name()), entry.Name(), check)...)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func loadDir(dir, model string, check func(name, text string) error) (errs []error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+suffix))
+	if err != nil {
+		return []error{err}
+	}
+	for _, path := range files {
+		name := strings.TrimSuffix(filepath.Base(path), suffix)
+		if _, ok := builtins[name]; !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown template %s, known are: %s", path, name, strings.Join(Names(), ", ")))
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err = check(name, string(content)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		if overrides[model] == nil {
+			overrides[model] = make(map[string]override)
+		}
+		overrides[model][name] = override{text: string(content), path: path}
+	}
+	return
+}
@@ -15,6 +15,7 @@ Following mentioned files and directories are present on the system:
 {{- range $file := .Files}}
 {{ RenderFile $file }}
 {{- end }}
+{{- end }}
 {{- if .Commands }}
 Commands:{{ else }}
 No commands{{ end }}
@@ -36,5 +37,45 @@ Answer in short sentences.
 If your answer contains a shell command start it with <command> and end it with </command>.
 If you answer contains a new configuration start the changed file with <file id=filename> and end it with </file>.
 {{ .Context }}
+{{- if .History }}
+This is the conversation so far:
+{{ range $turn := .History }}{{ $turn }}
+{{ end }}
+{{- end }}
+{{- if .Input }}
+The user provided following input, e.g. the output of a command:
+{{ .Input }}
+{{- end }}
 The user wants help with following task:
 {{ .Task }}`
+
+const RewriteQuery = `Given the following conversation and a follow up question, rewrite the
+follow up question to a standalone search query for a documentation database.
+{{- if .Multi }}
+If the question covers several topics, write up to three queries, one per line.
+{{- end }}
+Answer only with the query and nothing else.
+Conversation:
+{{ range $turn := .History }}{{ $turn }}
+{{ end }}
+Follow up question: {{ .Task }}`
+
+const CommandResults = `The user executed the commands you proposed, these are the results:
+{{ range $res := . }}
+Command: {{ $res.Command }}
+Exit code: {{ $res.ExitCode }}
+{{- if $res.Stdout }}
+Output:
+{{ $res.Stdout }}{{ end }}
+{{- if $res.Stderr }}
+Error output:
+{{ $res.Stderr }}{{ end }}
+{{ end }}
+Explain the results shortly and tell the user if further steps are needed.`
+
+const AgentPrompt = `Your name is Kowlaski and you are a helpfull assistant for a {{ .Name }} {{ .Version }} system.
+You can inspect the system with the given tools before you answer, but
+only call the tools which are really needed.
+Answer in short sentences.
+If your answer contains a shell command start it with <command> and end it with </command>.
+If you answer contains a new configuration start the changed file with <file id=filename> and end it with </file>.`
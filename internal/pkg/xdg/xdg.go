@@ -0,0 +1,35 @@
+// directories of kowalski according to the XDG base directory specification
+package xdg
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const appName = "kowalski"
+
+func home() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return os.TempDir()
+	}
+	return home
+}
+
+// $XDG_STATE_HOME/kowalski/elem..., defaults to ~/.local/state/kowalski
+func StateHome(elem ...string) string {
+	base := os.Getenv("XDG_STATE_HOME")
+	if base == "" {
+		base = filepath.Join(home(), ".local", "state")
+	}
+	return filepath.Join(append([]string{base, appName}, elem...)...)
+}
+
+// $XDG_CONFIG_HOME/kowalski/elem..., defaults to ~/.config/kowalski
+func ConfigHome(elem ...string) string {
+	base := os.Getenv("XDG_CONFIG_HOME")
+	if base == "" {
+		base = filepath.Join(home(), ".config")
+	}
+	return filepath.Join(append([]string{base, appName}, elem...)...)
+}